@@ -8,9 +8,12 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/gotpl/gtfmt/gtfmt"
+	"github.com/gotpl/gtfmt/internal/config"
 )
 
 // Main is the main entrypoint for the gtfix binary.
@@ -18,8 +21,24 @@ func Main() int {
 	return ParseAndRun(os.Stdout, os.Stderr, os.Stdin, os.Args[1:])
 }
 
+// subcommands maps the name of each subcommand to the function that runs it
+// with the remaining arguments.
+var subcommands = map[string]func(stdout, stderr io.Writer, stdin io.Reader, args []string) int{
+	"defs":   runDefs,
+	"fields": runFields,
+	"funcs":  runFuncs,
+	"graph":  runGraph,
+	"lsp":    runLSP,
+	"vet":    runVet,
+}
+
 // ParseAndRun parses the command line, and then runs gtfix.
 func ParseAndRun(stdout, stderr io.Writer, stdin io.Reader, args []string) int {
+	if len(args) > 0 {
+		if run, ok := subcommands[args[0]]; ok {
+			return run(stdout, stderr, stdin, args[1:])
+		}
+	}
 	log := log.New(stderr, "", 0)
 	c, err := Parse(stdout, args)
 	if err == flag.ErrHelp {
@@ -33,28 +52,80 @@ func ParseAndRun(stdout, stderr io.Writer, stdin io.Reader, args []string) int {
 	c.Stdin = stdin
 	c.Stdout = stdout
 	if err := c.Run(); err != nil {
-		log.Println("ERROR: ", err)
+		logError(log, err)
 		return 1
 	}
 	return 0
 }
 
+// logError logs err, following each error in a template with the line it is
+// on.
+func logError(log *log.Logger, err error) {
+	var errs []error
+	switch err := err.(type) {
+	case gtfmt.BlockErrors:
+		errs = err
+	case gtfmt.ErrorList:
+		for _, e := range err {
+			errs = append(errs, e)
+		}
+	default:
+		errs = []error{err}
+	}
+	for _, err := range errs {
+		log.Println("ERROR: ", err)
+		if gerr, ok := err.(*gtfmt.Error); ok {
+			log.Println(gerr.Snippet)
+		}
+	}
+}
+
 // Parse parses the given args.
 func Parse(stdout io.Writer, args []string) (*Command, error) {
 	fs := flag.FlagSet{}
 	fs.SetOutput(stdout)
 	c := &Command{}
-	var replace string
+	var replace, lines string
 	fs.StringVar(&replace, "r", "", "rewrite rule e.g. '.Foo.Bar -> .Foo.Baz.Bar'")
 	fs.BoolVar(&c.List, "l", false, "list templates that would be updated (but don't update them)")
+	fs.BoolVar(&c.AllErrors, "e", false, "report all syntax errors in a template, not just the first")
+	fs.BoolVar(&c.Partial, "partial", false, "format each action from its tokens alone, so templates that don't parse, such as work in progress, are still formatted, keeping the whitespace around actions")
+	fs.StringVar(&lines, "lines", "", "only format actions on the given lines e.g. '10:40'")
+	fs.StringVar(&c.DiffBase, "diff-base", "", "only format actions on lines changed since the given git revision")
+	var simplify bool
+	var rules string
+	fs.BoolVar(&simplify, "s", false, "simplify templates as well as formatting them")
+	fs.StringVar(&rules, "simplify", "", "only make the given simplifications: if-with, parens, printf, not-not, empty-else (comma separated, implies -s)")
+	fs.BoolVar(&c.Helm, "helm", false, "format the templates of the Helm charts in the given directories (default .), keeping the whitespace around actions")
+	fs.BoolVar(&c.Hugo, "hugo", false, "format the layouts of the Hugo sites or themes in the given directories (default .), keeping the whitespace around actions")
+	var jsonOut bool
+	fs.BoolVar(&jsonOut, "json", false, "report the result for each file as a JSON object (same as -format json)")
+	fs.StringVar(&c.Format, "format", "", "report results in the given format: json or sarif")
 	fs.Usage = func() {
 		fmt.Fprintln(stdout, `usage: gtfmt [options] [file1] <[file2]...>
+       gtfmt lsp
+       gtfmt vet [options] [file1] <[file2]...>
+       gtfmt defs [options] [file1] <[file2]...>
+       gtfmt graph [options] [file1] <[file2]...>
+       gtfmt fields [options] [file1] <[file2]...>
+       gtfmt funcs [options] [file1] <[file2]...>
 
 Reformats one or more go templates. If not given a filename, will read from stdin.
+In .go files, the templates in string literals passed to Parse are reformatted,
+and in .md files, code blocks tagged gotemplate, go-template or tmpl. With
+-helm or -hugo, the arguments are Helm chart or Hugo site directories.
+Settings are also read from .gtfmt.toml files; see the README.
+
+The lsp command runs a language server on stdin and stdout. The vet command
+reports problems in templates; see gtfmt vet -h. The defs command reports
+unused, duplicate and undefined templates across files, and the graph command
+writes which templates call which. The fields command reports which paths in
+the data the templates use, and the funcs command lists the calls of each
+function. See gtfmt <command> -h for details.
 
 Options:`)
 		fs.PrintDefaults()
-		fmt.Fprintln(stdout, `
+		io.WriteString(stdout, `
 
 Rewrite rules:
   ** this is still in alpha and subject to change **
@@ -69,36 +140,145 @@ Rewrite rules:
     foo -> bar
 
     The lack of a . indicates this is a function replacement.
+
+Simplifications:
+  Each simplification leaves the output of the template unchanged.
+
+  * if-with:    {{if .X}}{{.X.Y}}{{end}} -> {{with .X}}{{.Y}}{{end}}
+  * parens:     {{printf "%d" (.X)}} -> {{printf "%d" .X}}
+  * printf:     {{printf "%s" (html .X)}} -> {{html .X}} (strings only)
+  * not-not:    {{if not (not .X)}} -> {{if .X}}
+  * empty-else: {{if .X}}a{{else}}{{end}} -> {{if .X}}a{{end}}
+
 `)
 	}
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
 	if replace != "" {
-		vals := strings.Split(replace, " -> ")
-		if len(vals) != 2 || vals[0] == "" {
-			return nil, errors.New("rewrite rule must be in the format 'foo -> bar'")
+		var err error
+		if c.Orig, c.Replace, err = parseRule(replace); err != nil {
+			return nil, err
+		}
+	}
+	if lines != "" {
+		if replace != "" {
+			return nil, errors.New("-lines may not be used with a rewrite rule")
 		}
-		c.Orig = vals[0]
-		c.Replace = vals[1]
+		r, err := parseLines(lines)
+		if err != nil {
+			return nil, err
+		}
+		c.Lines = []gtfmt.LineRange{r}
+	}
+	if simplify {
+		c.Simplify = gtfmt.SimplifyAll
+	}
+	if rules != "" {
+		simp, err := gtfmt.ParseSimplification(rules)
+		if err != nil {
+			return nil, err
+		}
+		c.Simplify = simp
+	}
+	if c.Simplify != 0 && (replace != "" || lines != "" || c.DiffBase != "") {
+		return nil, errors.New("-s may not be used with -lines, -diff-base or a rewrite rule")
+	}
+	if c.Partial && (replace != "" || lines != "" || c.DiffBase != "" || c.Simplify != 0) {
+		return nil, errors.New("-partial may not be used with -lines, -diff-base, -s or a rewrite rule")
+	}
+	if jsonOut {
+		c.Format = formatJSON
+	}
+	switch c.Format {
+	case "", formatJSON, formatSARIF:
+	default:
+		return nil, fmt.Errorf("unknown output format %q", c.Format)
 	}
 	c.Files = fs.Args()
+	if c.Helm || c.Hugo {
+		if replace != "" || lines != "" || c.DiffBase != "" || c.Simplify != 0 {
+			return nil, errors.New("-helm and -hugo may not be used with -lines, -diff-base, -s or a rewrite rule")
+		}
+		project := &projectFlags{helm: c.Helm, hugo: c.Hugo}
+		files, err := project.files(c.Files)
+		if err != nil {
+			return nil, err
+		}
+		c.Files = files
+	}
+	if c.DiffBase != "" {
+		if replace != "" || lines != "" {
+			return nil, errors.New("-diff-base may not be used with -lines or a rewrite rule")
+		}
+		if len(c.Files) == 0 {
+			return nil, errors.New("-diff-base requires at least one file")
+		}
+	}
 	return c, nil
 }
 
+// parseLines parses a line range in the format 'first:last' or 'line', where
+// first is at least 1 and last is no less than first.
+func parseLines(s string) (gtfmt.LineRange, error) {
+	vals := strings.Split(s, ":")
+	if len(vals) > 2 {
+		return gtfmt.LineRange{}, errors.New("line range must be in the format 'first:last'")
+	}
+	first, err := strconv.Atoi(vals[0])
+	if err != nil {
+		return gtfmt.LineRange{}, errors.New("line range must be in the format 'first:last'")
+	}
+	last := first
+	if len(vals) == 2 {
+		last, err = strconv.Atoi(vals[1])
+		if err != nil {
+			return gtfmt.LineRange{}, errors.New("line range must be in the format 'first:last'")
+		}
+	}
+	// Lines are numbered from 1, and the range may not be empty.
+	if first < 1 || last < first {
+		return gtfmt.LineRange{}, errors.New("line range must start at 1 or later and not end before it starts")
+	}
+	return gtfmt.LineRange{First: first, Last: last}, nil
+}
+
 // Command is a Command to run.
 type Command struct {
-	Orig    string
-	Replace string
-	List    bool // if true, only list what files need formatting
-	Files   []string
-	Stdout  io.Writer
-	Stdin   io.Reader
-	Stderr  io.Writer
+	Orig      string
+	Replace   string
+	List      bool                 // if true, only list what files need formatting
+	Lines     []gtfmt.LineRange    // if set, only format actions on these lines
+	DiffBase  string               // if set, only format lines changed since this git revision
+	Simplify  gtfmt.Simplification // simplifications to make while formatting
+	Helm      bool                 // if true, Files are Helm chart templates
+	Hugo      bool                 // if true, Files are Hugo layouts
+	Format    string               // if set, report results in this format instead of writing text
+	AllErrors bool                 // if true, report every syntax error in a template
+	Partial   bool                 // if true, format actions from their tokens alone
+	Files     []string
+	Stdout    io.Writer
+	Stdin     io.Reader
+	Stderr    io.Writer
+
+	configs config.Loader // the configs of the files' directories
 }
 
 // Run runs the command
 func (c *Command) Run() error {
+	if len(c.Files) > 0 {
+		files, err := expandFiles(&c.configs, c.Files)
+		if err != nil {
+			return err
+		}
+		c.Files = files
+	}
+	switch c.Format {
+	case formatJSON:
+		return c.runJSON()
+	case formatSARIF:
+		return c.runSARIF()
+	}
 	if c.Orig == "" {
 		return c.format()
 	}
@@ -115,21 +295,28 @@ func (c *Command) format() error {
 			return err
 		}
 		orig := string(b)
-		if c.List {
-			ok, err := gtfmt.Formatted(fn, orig)
+		lines := c.Lines
+		if c.DiffBase != "" {
+			lines, err = changedLines(c.DiffBase, fn)
 			if err != nil {
 				return err
 			}
-			if !ok {
-				io.WriteString(c.Stdout, fn+"\n")
+			if len(lines) == 0 {
+				continue
 			}
-			continue
 		}
-		s, err := gtfmt.Format(fn, orig)
-		if err != nil {
+		s, err := c.formatText(fn, orig, lines)
+		// The blocks of a Markdown file that could be parsed are still
+		// formatted.
+		blockErrs, partial := err.(gtfmt.BlockErrors)
+		if err != nil && !partial {
 			return err
 		}
-		if s != orig {
+		if c.List {
+			if s != orig {
+				io.WriteString(c.Stdout, fn+"\n")
+			}
+		} else if s != orig {
 			info, err := os.Stat(fn)
 			if err != nil {
 				return err
@@ -139,6 +326,9 @@ func (c *Command) format() error {
 				return err
 			}
 		}
+		if partial {
+			return blockErrs
+		}
 	}
 	return nil
 }
@@ -149,26 +339,148 @@ func (c *Command) fmtStdin() error {
 		return err
 	}
 	orig := string(b)
+	s, err := c.formatText("stdin", orig, c.Lines)
+	if err != nil {
+		return err
+	}
 	if c.List {
-		ok, err := gtfmt.Formatted("stdin", orig)
-		if err != nil {
-			return err
-		}
-		if ok {
+		if s == orig {
 			io.WriteString(c.Stdout, "formatted\n")
 		} else {
 			io.WriteString(c.Stdout, "unformatted\n")
 		}
 		return nil
 	}
-	s, err := gtfmt.Format("stdin", orig)
-	if err != nil {
-		return err
-	}
 	_, err = io.WriteString(c.Stdout, s)
 	return err
 }
 
+// formatText formats the given template, limited to lines if set, following
+// the config of its directory. The templates in a Go or Markdown file are
+// formatted in place, and Helm chart templates, Hugo layouts and templates
+// with custom delimiters keep the whitespace around their actions.
+func (c *Command) formatText(name, tpl string, lines []gtfmt.LineRange) (string, error) {
+	edits, err := c.formatEdits(name, tpl, lines)
+	// The blocks of a Markdown file that could be parsed are still formatted.
+	if _, partial := err.(gtfmt.BlockErrors); err != nil && !partial {
+		return "", err
+	}
+	s, aerr := gtfmt.Apply(tpl, edits)
+	if aerr != nil {
+		return "", aerr
+	}
+	return s, err
+}
+
+// formatEdits returns the edits that formatText makes to the given template:
+// one for each action changed, or for each run of changed lines in a Go or
+// Markdown file. Those formatting the blocks of a Markdown file that parse
+// are returned along with the BlockErrors of those that don't.
+func (c *Command) formatEdits(name, tpl string, lines []gtfmt.LineRange) ([]gtfmt.TextEdit, error) {
+	cfg, err := configFor(&c.configs, name)
+	if err != nil {
+		return nil, err
+	}
+	opts := gtfmt.Options{
+		LeftDelim:  cfg.LeftDelim,
+		RightDelim: cfg.RightDelim,
+		KeepSpace:  c.Helm || c.Hugo || c.Partial || cfg.LeftDelim != "",
+		AllErrors:  c.AllErrors,
+		Partial:    c.Partial,
+	}
+	if opts.KeepSpace {
+		if len(lines) > 0 || c.Simplify != 0 {
+			return nil, fmt.Errorf("%s: -lines, -diff-base and -s are not supported with custom delimiters", name)
+		}
+	} else {
+		switch filepath.Ext(name) {
+		case ".go":
+			if len(lines) > 0 || c.Simplify != 0 {
+				return nil, fmt.Errorf("%s: -lines, -diff-base and -s are not supported for Go files", name)
+			}
+			s, err := gtfmt.FormatGo(name, tpl)
+			if err != nil {
+				return nil, err
+			}
+			return gtfmt.Diff(tpl, s), nil
+		case ".md", ".markdown":
+			if len(lines) > 0 || c.Simplify != 0 {
+				return nil, fmt.Errorf("%s: -lines, -diff-base and -s are not supported for Markdown files", name)
+			}
+			s, err := gtfmt.FormatMarkdown(name, tpl)
+			if _, partial := err.(gtfmt.BlockErrors); err != nil && !partial {
+				return nil, err
+			}
+			return gtfmt.Diff(tpl, s), err
+		}
+		if len(lines) > 0 {
+			return gtfmt.LinesEdits(name, tpl, lines...)
+		}
+		opts.Simplify = c.Simplify
+		if opts.Simplify == 0 && cfg.Simplify != "" {
+			if opts.Simplify, err = parseSimplify(cfg.Simplify); err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	rewrites := cfg.Rewrite
+	if c.Partial {
+		rewrites = nil // rewrite rules need a parsed template
+	}
+	for _, rule := range rewrites {
+		orig, repl, err := parseRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		opts.Rules = append(opts.Rules, gtfmt.Rule{Orig: orig, Repl: repl})
+	}
+	f, err := gtfmt.NewFormatter(opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.Edits(gtfmt.Source{Filename: name, Text: []byte(tpl)})
+}
+
+// rewriteText applies the rewrite rule to the given template, with the
+// delimiters set by the config of its directory, returning the new text and
+// the number of replacements made.
+func (c *Command) rewriteText(name, tpl string) (string, int, error) {
+	edits, n, err := c.rewriteEdits(name, tpl)
+	if err != nil {
+		return "", 0, err
+	}
+	s, err := gtfmt.Apply(tpl, edits)
+	return s, n, err
+}
+
+// rewriteEdits returns the edits that rewriteText makes to the given
+// template, one for each action changed, and the number of replacements.
+func (c *Command) rewriteEdits(name, tpl string) ([]gtfmt.TextEdit, int, error) {
+	cfg, err := configFor(&c.configs, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !c.Helm && !c.Hugo && cfg.LeftDelim == "" {
+		// Go and Markdown files are formatted by the templates they hold,
+		// which rules don't yet look into.
+		switch filepath.Ext(name) {
+		case ".go":
+			return nil, 0, fmt.Errorf("%s: rewrite rules are not supported for Go files", name)
+		case ".md", ".markdown":
+			return nil, 0, fmt.Errorf("%s: rewrite rules are not supported for Markdown files", name)
+		}
+	}
+	f, err := gtfmt.NewFormatter(gtfmt.Options{
+		LeftDelim:  cfg.LeftDelim,
+		RightDelim: cfg.RightDelim,
+		Rules:      []gtfmt.Rule{{Orig: c.Orig, Repl: c.Replace}},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return f.EditsCount(gtfmt.Source{Filename: name, Text: []byte(tpl)})
+}
+
 func (c *Command) replace() error {
 	if len(c.Files) == 0 {
 		return c.replaceStdin()
@@ -179,7 +491,7 @@ func (c *Command) replace() error {
 			return err
 		}
 		tpl := string(b)
-		s, err := gtfmt.Fix(fn, tpl, c.Orig, c.Replace)
+		s, _, err := c.rewriteText(fn, tpl)
 		if err != nil {
 			return err
 		}
@@ -209,7 +521,7 @@ func (c *Command) replaceStdin() error {
 		return err
 	}
 	tpl := string(b)
-	s, err := gtfmt.Fix("stdin", tpl, c.Orig, c.Replace)
+	s, _, err := c.rewriteText("stdin", tpl)
 	if err != nil {
 		return err
 	}
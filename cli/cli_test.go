@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/gotpl/gtfmt/gtfmt"
 )
 
 func TestParseReplace(t *testing.T) {
@@ -62,6 +64,22 @@ func TestFmtStdin(t *testing.T) {
 	}
 }
 
+func TestFmtPartialStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	stdin := bytes.NewBufferString("{{  if  .A }}\n  {{-  .B  }}\n")
+	code := ParseAndRun(&stdout, &stderr, stdin, []string{"-partial"})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	expected := "{{if .A}}\n  {{- .B}}\n"
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
 func TestFmtFiles(t *testing.T) {
 	dir, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -107,6 +125,90 @@ func TestFmtFiles(t *testing.T) {
 	}
 }
 
+func TestFmtGoFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "tmpl.go")
+	src := "package tmpl\n\nimport \"text/template\"\n\nvar t = template.Must(template.New(\"t\").Parse(`{{  .X  }}`))\n"
+	err = ioutil.WriteFile(fn, []byte(src), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{fn})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	expected := []byte("package tmpl\n\nimport \"text/template\"\n\nvar t = template.Must(template.New(\"t\").Parse(`{{.X}}`))\n")
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, expected) {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, b)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestFmtMarkdownFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "doc.md")
+	src := "# Doc\n\n```tmpl\n{{  .A  }}\n```\n\n```tmpl\n{{ .B ) }}\n```\n"
+	err = ioutil.WriteFile(fn, []byte(src), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{fn})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := []byte("# Doc\n\n```tmpl\n{{.A}}\n```\n\n```tmpl\n{{ .B ) }}\n```\n")
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, expected) {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, b)
+	}
+	expectedErr := "ERROR:  " + fn + ":8:7: unexpected \")\" in input\n{{ .B ) }}\n      ^\n"
+	if s := stderr.String(); s != expectedErr {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expectedErr, s)
+	}
+}
+
+func TestFmtAllErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "a.tmpl")
+	err = ioutil.WriteFile(fn, []byte("{{.A ) }}\n{{if}}\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-e", fn})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := "ERROR:  " + fn + ":1:6: unexpected \")\" in input\n{{.A ) }}\n     ^\n" +
+		"ERROR:  " + fn + ":2:5: missing value for if\n{{if}}\n    ^\n"
+	if s := stderr.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+}
+
 func TestReplaceFiles(t *testing.T) {
 	dir, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -152,6 +254,66 @@ func TestReplaceFiles(t *testing.T) {
 	}
 }
 
+func TestReplaceGoFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "tmpl.go")
+	src := []byte("package tmpl\n\nconst t = `{{index .A 1}}`\n")
+	err = ioutil.WriteFile(fn, src, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-r", "index -> strings.Index", fn})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := "ERROR:  " + fn + ": rewrite rules are not supported for Go files\n"
+	if s := stderr.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, src) {
+		t.Error("contents of Go file were changed but should not have been")
+	}
+}
+
+func TestReplaceMarkdownFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "doc.md")
+	src := []byte("# Doc\n\n```tmpl\n{{index .A 1}}\n```\n")
+	err = ioutil.WriteFile(fn, src, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-r", "index -> strings.Index", fn})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := "ERROR:  " + fn + ": rewrite rules are not supported for Markdown files\n"
+	if s := stderr.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, src) {
+		t.Error("contents of Markdown file were changed but should not have been")
+	}
+}
+
 func TestList(t *testing.T) {
 	dir, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -197,3 +359,93 @@ func TestList(t *testing.T) {
 		t.Errorf("Expected only file1 to be listed, but got\n%s", s)
 	}
 }
+
+func TestFmtLinesStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	stdin := bytes.NewBufferString("{{  .A  }}\n{{  .B  }}\n{{  .C  }}\n")
+	code := ParseAndRun(&stdout, &stderr, stdin, []string{"-lines", "2:3"})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	expected := "{{  .A  }}\n{{.B}}\n{{.C}}\n"
+
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	for s, expected := range map[string]gtfmt.LineRange{
+		"3":   {First: 3, Last: 3},
+		"2:4": {First: 2, Last: 4},
+		"5:5": {First: 5, Last: 5},
+	} {
+		r, err := parseLines(s)
+		if err != nil || r != expected {
+			t.Errorf("%q: expected %v but got %v, %v", s, expected, r, err)
+		}
+	}
+	const (
+		malformed = "line range must be in the format 'first:last'"
+		empty     = "line range must start at 1 or later and not end before it starts"
+	)
+	for s, expected := range map[string]string{
+		"40:10": empty,
+		"0:5":   empty,
+		"0":     empty,
+		"-3":    empty,
+		"-3:4":  empty,
+		"1:-2":  empty,
+		"1:2:3": malformed,
+		"a:b":   malformed,
+		"3:":    malformed,
+	} {
+		_, err := parseLines(s)
+		if err == nil || err.Error() != expected {
+			t.Errorf("%q: expected error %q but got %v", s, expected, err)
+		}
+	}
+}
+
+func TestParseLinesWithReplace(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	_, err := Parse(stdout, []string{"-lines", "1:2", "-r", "foo -> bar"})
+	if err == nil {
+		t.Fatal("expected error using -lines with -r")
+	}
+}
+
+func TestSimplifyStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	stdin := bytes.NewBufferString(`{{if  .X}}{{ .X.Y }}{{else}}{{end}}`)
+	code := ParseAndRun(&stdout, &stderr, stdin, []string{"-s"})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	expected := `{{with .X}}{{.Y}}{{end}}`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestParseSimplify(t *testing.T) {
+	c, err := Parse(&bytes.Buffer{}, []string{"-simplify", "parens,not-not"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := gtfmt.SimplifyParens | gtfmt.SimplifyNot; c.Simplify != expected {
+		t.Errorf("expected %v but got %v", expected, c.Simplify)
+	}
+	if _, err := Parse(&bytes.Buffer{}, []string{"-s", "-lines", "1"}); err == nil {
+		t.Error("expected error using -s with -lines")
+	}
+	if _, err := Parse(&bytes.Buffer{}, []string{"-simplify", "nope"}); err == nil {
+		t.Error("expected error for unknown simplification")
+	}
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotpl/gtfmt/gtfmt"
+	"github.com/gotpl/gtfmt/internal/config"
+)
+
+// expandFiles returns the files to read for args, leaving out those their
+// config excludes: each file given, and the files in each directory given
+// that their config includes.
+func expandFiles(configs *config.Loader, args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			cfg, err := configs.For(arg)
+			if err != nil {
+				return nil, err
+			}
+			if !cfg.Excluded(arg) {
+				files = append(files, arg)
+			}
+			continue
+		}
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if path != arg && strings.HasPrefix(info.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			cfg, err := configs.For(path)
+			if err != nil {
+				return err
+			}
+			if cfg.Included(path) && !cfg.Excluded(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// configFor returns the config of the named file, or of the current
+// directory for stdin.
+func configFor(configs *config.Loader, name string) (*config.Config, error) {
+	if name == "stdin" {
+		return configs.Dir(".")
+	}
+	return configs.For(name)
+}
+
+// parseRule parses a rewrite rule in the format 'foo -> bar'.
+func parseRule(rule string) (orig, repl string, err error) {
+	vals := strings.Split(rule, " -> ")
+	if len(vals) != 2 || vals[0] == "" {
+		return "", "", errors.New("rewrite rule must be in the format 'foo -> bar'")
+	}
+	return vals[0], vals[1], nil
+}
+
+// parseSimplify parses the simplify setting of a config: the names of
+// simplifications as for -simplify, or "all".
+func parseSimplify(s string) (gtfmt.Simplification, error) {
+	if s == "all" {
+		return gtfmt.SimplifyAll, nil
+	}
+	return gtfmt.ParseSimplification(s)
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes files, by path relative to a new directory, to it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFmtConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".gtfmt.toml":        "root = true\nsimplify = \"parens\"\nrewrite = [\"old -> new\"]\nexclude = [\"skip/**\"]\n",
+		"a.tmpl":             "{{  old  (.X)  }}",
+		"page.html":          "{{  .X  }}",
+		"skip/b.tmpl":        "{{  .X  }}",
+		"legacy/.gtfmt.toml": "delims = [\"[[\", \"]]\"]\n",
+		"legacy/c.tmpl":      "[[-   .X -]] {{  .Y  }}",
+	})
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{dir, filepath.Join(dir, "skip", "b.tmpl")})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+	expected := map[string]string{
+		"a.tmpl":        "{{new .X}}",
+		"page.html":     "{{  .X  }}",
+		"skip/b.tmpl":   "{{  .X  }}",
+		"legacy/c.tmpl": "[[- .X -]] {{  .Y  }}",
+	}
+	for name, text := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != text {
+			t.Errorf("%s: expected:\n%s\nbut got:\n%s", name, text, b)
+		}
+	}
+}
+
+func TestDelimsConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".gtfmt.toml": "root = true\ndelims = [\"[[\", \"]]\"]\n",
+		"a.tmpl":      "[[  foo .X  ]] {{ x }}",
+	})
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "a.tmpl")
+
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-r", "foo -> bar", fn})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d: %s", code, stderr.String())
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[[bar .X]] {{ x }}"
+	if string(b) != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, b)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = ParseAndRun(&stdout, &stderr, nil, []string{"funcs", fn})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d: %s", code, stderr.String())
+	}
+	expected = "bar\t1\n\t" + fn + ":1:3\t1 arg\n"
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = ParseAndRun(&stdout, &stderr, nil, []string{"fields", fn})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d: %s", code, stderr.String())
+	}
+	expected = ".X\t1\t" + fn + ":1:7\n"
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+}
+
+func TestVetFixDelimsConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".gtfmt.toml": "root = true\ndelims = [\"[[\", \"]]\"]\n",
+		"a.tmpl":      "[[$z := 1]][[ $x := .A ]][[ $x ]] literal: {{$y := 1}}",
+	})
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "a.tmpl")
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"vet", "-fix", fn})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[[ $x := .A ]][[ $x ]] literal: {{$y := 1}}"
+	if string(b) != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, b)
+	}
+}
+
+func TestVetConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".gtfmt.toml":        "root = true\nfuncs = [\"shout\"]\n",
+		"a.tmpl":             "{{shout .X}}{{whisper .Y}}",
+		"legacy/.gtfmt.toml": "delims = [\"[[\", \"]]\"]\npresets = [\"sprig\"]\n",
+		"legacy/b.tmpl":      "[[shout .X | upper]]{{",
+	})
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"vet", dir})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := filepath.Join(dir, "a.tmpl") + ":1:15: function \"whisper\" not defined\n"
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/check"
+	"github.com/gotpl/gtfmt/internal/config"
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// runDefs reports unused, duplicate and undefined templates across files.
+func runDefs(stdout, stderr io.Writer, stdin io.Reader, args []string) int {
+	log := log.New(stderr, "", 0)
+	fs := flag.FlagSet{}
+	fs.SetOutput(stderr)
+	roots := fs.String("root", "", "names of templates executed directly by the program, as well as each file's top level template (comma separated)")
+	project := addProjectFlags(&fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, `usage: gtfmt defs [options] [file1] <[file2]...>
+
+Reports problems with the templates defined across a set of files, as parsed
+together by ParseFiles or ParseGlob: templates defined with define or block
+that are never reached from a top level template, templates defined more than
+once, and calls of templates that aren't defined.
+
+Options:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	files, err := project.files(fs.Args())
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	var rootNames []string
+	if *roots != "" {
+		rootNames = strings.Split(*roots, ",")
+	}
+
+	g := project.graph()
+	texts, problems, err := loadGraph(g, files)
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	// Templates may be reached through files that could not be parsed.
+	if len(problems) == 0 {
+		for _, t := range g.Unused(rootNames...) {
+			problems = append(problems, problem{t.File, t.Pos, fmt.Sprintf("template %q defined and not used", t.Name)})
+		}
+	}
+	// Hugo executes each layout with its own base template, so each may
+	// define the same templates.
+	var dups [][2]*check.Template
+	if !project.hugo {
+		dups = g.Duplicates()
+	}
+	for _, d := range dups {
+		first := newPos(texts[d[1].File], int(d[1].Pos))
+		msg := fmt.Sprintf("template %q already defined at %s:%d:%d", d[0].Name, d[1].File, first.Line, first.Column)
+		problems = append(problems, problem{d[0].File, d[0].Pos, msg})
+	}
+	for _, c := range g.Undefined() {
+		problems = append(problems, problem{c.File, c.Pos, fmt.Sprintf("template %q not defined", c.Name)})
+	}
+
+	printProblems(stdout, files, texts, problems)
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// loadGraph reads and parses files into g, with the delimiters set by the
+// config of each file's directory, returning the text of each file, and a
+// problem for each that could not be parsed.
+func loadGraph(g *check.Graph, files []string) (map[string]string, []problem, error) {
+	texts := map[string]string{}
+	var problems []problem
+	var configs config.Loader
+	for _, fn := range files {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg, err := configs.For(fn)
+		if err != nil {
+			return nil, nil, err
+		}
+		texts[fn] = string(b)
+		if err := g.AddDelims(fn, string(b), cfg.LeftDelim, cfg.RightDelim); err != nil {
+			p := problem{file: fn, msg: err.Error()}
+			if perr, ok := err.(*parse.Error); ok {
+				p.pos, p.msg = perr.Pos, perr.Msg
+			}
+			problems = append(problems, p)
+		}
+	}
+	return texts, problems, nil
+}
+
+// printProblems prints problems in the order of files, then position.
+func printProblems(w io.Writer, files []string, texts map[string]string, problems []problem) {
+	order := map[string]int{}
+	for i, fn := range files {
+		if _, ok := order[fn]; !ok {
+			order[fn] = i
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.file != b.file {
+			return order[a.file] < order[b.file]
+		}
+		return a.pos < b.pos
+	})
+	for _, p := range problems {
+		pos := newPos(texts[p.file], int(p.pos))
+		fmt.Fprintf(w, "%s:%d:%d: %s\n", p.file, pos.Line, pos.Column, p.msg)
+	}
+}
+
+// problem is a problem found in one of a set of files.
+type problem struct {
+	file string
+	pos  parse.Pos
+	msg  string
+}
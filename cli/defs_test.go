@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	page := filepath.Join(dir, "page.tmpl")
+	parts := filepath.Join(dir, "parts.tmpl")
+	more := filepath.Join(dir, "more.tmpl")
+	for fn, cont := range map[string]string{
+		page:  "{{template \"header\" .}}\n{{template \"footer\" .}}",
+		parts: "{{define \"header\"}}h{{end}}\n{{define \"old\"}}o{{end}}\n{{define \"email\"}}e{{end}}",
+		more:  "{{define \"header\"}}h2{{end}}",
+	} {
+		if err := ioutil.WriteFile(fn, []byte(cont), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"defs", "-root", "email", page, parts, more})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := page + `:2:12: template "footer" not defined
+` + parts + `:2:10: template "old" defined and not used
+` + more + `:1:10: template "header" already defined at ` + parts + `:1:10
+`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
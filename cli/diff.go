@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gotpl/gtfmt/gtfmt"
+)
+
+// changedLines returns the lines of file that differ from the given git
+// revision, according to git diff. Files not tracked by git are considered
+// changed in their entirety.
+func changedLines(rev, file string) ([]gtfmt.LineRange, error) {
+	dir, base := filepath.Split(file)
+	if dir == "" {
+		dir = "."
+	}
+	tracked, err := git(dir, "ls-files", "--", base)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(tracked)) == 0 {
+		return []gtfmt.LineRange{{First: 1, Last: int(^uint(0) >> 1)}}, nil
+	}
+	out, err := git(dir, "diff", "--no-color", "--no-ext-diff", "-U0", rev, "--", base)
+	if err != nil {
+		return nil, err
+	}
+	return parseHunks(out)
+}
+
+// git runs git with the given args in dir and returns its stdout.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+// parseHunks returns the line ranges on the new side of each hunk in a
+// unified diff. Hunks that only delete lines are skipped.
+func parseHunks(diff []byte) ([]gtfmt.LineRange, error) {
+	var ranges []gtfmt.LineRange
+	// Split rather than scan, as changed lines may be arbitrarily long.
+	for _, b := range bytes.Split(diff, []byte("\n")) {
+		if !bytes.HasPrefix(b, []byte("@@ ")) {
+			continue
+		}
+		line := string(b)
+		// @@ -orig[,count] +new[,count] @@
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+			return nil, fmt.Errorf("malformed hunk header %q", line)
+		}
+		vals := strings.SplitN(fields[2][1:], ",", 2)
+		first, err := strconv.Atoi(vals[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed hunk header %q", line)
+		}
+		count := 1
+		if len(vals) == 2 {
+			count, err = strconv.Atoi(vals[1])
+			if err != nil {
+				return nil, fmt.Errorf("malformed hunk header %q", line)
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		ranges = append(ranges, gtfmt.LineRange{First: first, Last: first + count - 1})
+	}
+	return ranges, nil
+}
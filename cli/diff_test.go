@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gotpl/gtfmt/gtfmt"
+)
+
+func TestParseHunks(t *testing.T) {
+	diff := []byte(`diff --git a/foo b/foo
+index 1111111..2222222 100644
+--- a/foo
++++ b/foo
+@@ -2 +2 @@
+-{{.A}}
++{{  .B  }}
+@@ -5,0 +6,3 @@ header
++a
++b
++c
+@@ -9,2 +11,0 @@
+-x
+-y
+@@ -20 +20 @@
+-z
++` + strings.Repeat("x", 100000) + `
+`)
+	ranges, err := parseHunks(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []gtfmt.LineRange{{First: 2, Last: 2}, {First: 6, Last: 8}, {First: 20, Last: 20}}
+	if !reflect.DeepEqual(expected, ranges) {
+		t.Fatalf("Expected:\n%#v\n\ngot:\n%#v", expected, ranges)
+	}
+}
+
+func TestDiffBase(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	changed := filepath.Join(dir, "changed")
+	unchanged := filepath.Join(dir, "unchanged")
+	err = ioutil.WriteFile(changed, []byte("{{  .A  }}\n{{  .B  }}\n{{  .C  }}\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cont := []byte("{{  .A  }}\n")
+	err = ioutil.WriteFile(unchanged, cont, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run("init", "-q")
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	err = ioutil.WriteFile(changed, []byte("{{  .A  }}\n{{  .D  }}\n{{  .C  }}\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-diff-base", "HEAD", changed, unchanged})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d: %s", code, stderr.String())
+	}
+	expected := []byte("{{  .A  }}\n{{.D}}\n{{  .C  }}\n")
+	b, err := ioutil.ReadFile(changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, expected) {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, b)
+	}
+	b, err = ioutil.ReadFile(unchanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, cont) {
+		t.Error("contents of unchanged file were changed but should not have been")
+	}
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/check"
+)
+
+// runFields reports which paths in the data are used across files.
+func runFields(stdout, stderr io.Writer, stdin io.Reader, args []string) int {
+	log := log.New(stderr, "", 0)
+	fs := flag.FlagSet{}
+	fs.SetOutput(stderr)
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, `usage: gtfmt fields [options] [file1] <[file2]...>
+
+Reports the paths in the data, such as .Page.Title, that a set of files parsed
+together as by ParseFiles or ParseGlob uses, with the number of uses and where
+each is. Paths are resolved through with, range, variables and template calls
+where possible: .Items[].Name is the Name of an element of .Items, and a path
+starting with a pipeline in parentheses is a field of the pipeline's result.
+
+Options:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		log.Println("ERROR: ", fmt.Sprintf("unknown output format %q", *format))
+		return 1
+	}
+	if fs.NArg() == 0 {
+		log.Println("ERROR: ", "no files given")
+		return 1
+	}
+	g := &check.Graph{}
+	texts, problems, err := loadGraph(g, fs.Args())
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	if len(problems) > 0 {
+		printProblems(stderr, fs.Args(), texts, problems)
+		return 1
+	}
+
+	byPath := map[string]*fieldUsage{}
+	var usages []*fieldUsage
+	for _, u := range g.FieldUses() {
+		fu := byPath[u.Path]
+		if fu == nil {
+			fu = &fieldUsage{Field: u.Path}
+			byPath[u.Path] = fu
+			usages = append(usages, fu)
+		}
+		fu.Count++
+		fu.Uses = append(fu.Uses, fieldLocation{Path: u.File, Pos: newPos(texts[u.File], int(u.Pos))})
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Field < usages[j].Field })
+
+	if *format == "json" {
+		if usages == nil {
+			usages = []*fieldUsage{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(usages)
+	} else {
+		var b strings.Builder
+		for _, fu := range usages {
+			fmt.Fprintf(&b, "%s\t%d", fu.Field, fu.Count)
+			for _, loc := range fu.Uses {
+				fmt.Fprintf(&b, "\t%s:%d:%d", loc.Path, loc.Pos.Line, loc.Pos.Column)
+			}
+			b.WriteString("\n")
+		}
+		_, err = io.WriteString(stdout, b.String())
+	}
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	return 0
+}
+
+// fieldUsage is the uses of a path in the data.
+type fieldUsage struct {
+	Field string          `json:"field"`
+	Count int             `json:"count"`
+	Uses  []fieldLocation `json:"uses"`
+}
+
+type fieldLocation struct {
+	Path string  `json:"path"`
+	Pos  jsonPos `json:"pos"`
+}
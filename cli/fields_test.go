@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	page := filepath.Join(dir, "page.tmpl")
+	parts := filepath.Join(dir, "parts.tmpl")
+	for fn, cont := range map[string]string{
+		page:  "{{.Title}}\n{{range .Posts}}{{template \"post\" .}}{{end}}",
+		parts: "{{define \"post\"}}{{.Title}} {{.Author.Name}}{{end}}",
+	} {
+		if err := ioutil.WriteFile(fn, []byte(cont), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"fields", page, parts})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	expected := ".Posts\t1\t" + page + ":2:9\n" +
+		".Posts[].Author.Name\t1\t" + parts + ":1:31\n" +
+		".Posts[].Title\t1\t" + parts + ":1:20\n" +
+		".Title\t1\t" + page + ":1:3\n"
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+
+	stdout.Reset()
+	code = ParseAndRun(&stdout, &stderr, nil, []string{"fields", "-format", "json", page})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	var usages []fieldUsage
+	if err := json.Unmarshal(stdout.Bytes(), &usages); err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 2 || usages[0].Field != ".Posts" || usages[0].Count != 1 || usages[0].Uses[0].Pos.Line != 2 {
+		t.Errorf("unexpected usages %+v", usages)
+	}
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gotpl/gtfmt/gtfmt"
+	"github.com/gotpl/gtfmt/internal/config"
+)
+
+// runFuncs lists the calls of each function across files.
+func runFuncs(stdout, stderr io.Writer, stdin io.Reader, args []string) int {
+	log := log.New(stderr, "", 0)
+	fs := flag.FlagSet{}
+	fs.SetOutput(stderr)
+	format := fs.String("format", "text", "output format: text or json")
+	only := fs.String("func", "", "only list calls of the given function")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, `usage: gtfmt funcs [options] [file1] <[file2]...>
+
+Lists every function called in one or more go templates, with the number of
+calls and where each is, how many arguments it is passed, and whether it is
+passed the value of the previous command in a pipeline. If not given a
+filename, will read from stdin.
+
+Options:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		log.Println("ERROR: ", fmt.Sprintf("unknown output format %q", *format))
+		return 1
+	}
+
+	type file struct{ name, text string }
+	var files []file
+	if fs.NArg() == 0 {
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			log.Println("ERROR: ", err)
+			return 1
+		}
+		files = append(files, file{"stdin", string(b)})
+	}
+	for _, fn := range fs.Args() {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			log.Println("ERROR: ", err)
+			return 1
+		}
+		files = append(files, file{fn, string(b)})
+	}
+
+	byName := map[string]*funcUsage{}
+	var usages []*funcUsage
+	var configs config.Loader
+	for _, f := range files {
+		cfg, err := configFor(&configs, f.name)
+		if err != nil {
+			log.Println("ERROR: ", err)
+			return 1
+		}
+		calls, err := gtfmt.FuncCallsDelims(f.name, f.text, cfg.LeftDelim, cfg.RightDelim)
+		if err != nil {
+			log.Println("ERROR: ", err)
+			return 1
+		}
+		for _, c := range calls {
+			if *only != "" && c.Name != *only {
+				continue
+			}
+			fu := byName[c.Name]
+			if fu == nil {
+				fu = &funcUsage{Name: c.Name, Arities: map[string]int{}}
+				byName[c.Name] = fu
+				usages = append(usages, fu)
+			}
+			fu.Count++
+			fu.Arities[strconv.Itoa(c.Arity())]++
+			fu.Calls = append(fu.Calls, funcCall{
+				Path:  f.name,
+				Pos:   newPos(f.text, c.Pos),
+				Args:  c.Args,
+				Stage: c.Stage,
+				Piped: c.Piped,
+				Arg:   c.Arg,
+			})
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Name < usages[j].Name })
+
+	var err error
+	if *format == "json" {
+		if usages == nil {
+			usages = []*funcUsage{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(usages)
+	} else {
+		var b strings.Builder
+		for _, fu := range usages {
+			fmt.Fprintf(&b, "%s\t%d\n", fu.Name, fu.Count)
+			for _, c := range fu.Calls {
+				fmt.Fprintf(&b, "\t%s:%d:%d\t%s\n", c.Path, c.Pos.Line, c.Pos.Column, c.describe())
+			}
+		}
+		_, err = io.WriteString(stdout, b.String())
+	}
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	return 0
+}
+
+// funcUsage is the calls of a function.
+type funcUsage struct {
+	Name    string         `json:"name"`
+	Count   int            `json:"count"`
+	Arities map[string]int `json:"arities"` // number of calls by arity, including piped values
+	Calls   []funcCall     `json:"calls"`
+}
+
+type funcCall struct {
+	Path  string  `json:"path"`
+	Pos   jsonPos `json:"pos"`
+	Args  int     `json:"args"`
+	Stage int     `json:"stage"`
+	Piped bool    `json:"piped,omitempty"`
+	Arg   bool    `json:"arg,omitempty"`
+}
+
+// describe describes how the function is called.
+func (c funcCall) describe() string {
+	if c.Arg {
+		return "as an argument"
+	}
+	s := fmt.Sprintf("%d args", c.Args)
+	if c.Args == 1 {
+		s = "1 arg"
+	}
+	if c.Piped {
+		s += fmt.Sprintf(", piped (stage %d)", c.Stage+1)
+	}
+	return s
+}
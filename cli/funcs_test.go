@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestFuncs(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	stdin := bytes.NewBufferString("{{printf \"%s\" .X | upper}}\n{{upper .Y}} {{len (list)}}")
+	code := ParseAndRun(&stdout, &stderr, stdin, []string{"funcs"})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	expected := `len	1
+	stdin:2:16	1 arg
+list	1
+	stdin:2:21	0 args
+printf	1
+	stdin:1:3	2 args
+upper	2
+	stdin:1:20	0 args, piped (stage 2)
+	stdin:2:3	1 arg
+`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestFuncsFilterJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	stdin := bytes.NewBufferString("{{printf \"%s\" .X | upper}}\n{{upper .Y}}{{html upper}}")
+	code := ParseAndRun(&stdout, &stderr, stdin, []string{"funcs", "-func", "upper", "-format", "json"})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	var usages []funcUsage
+	if err := json.Unmarshal(stdout.Bytes(), &usages); err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 1 || usages[0].Name != "upper" || usages[0].Count != 3 {
+		t.Fatalf("unexpected usages %+v", usages)
+	}
+	if a := usages[0].Arities; a["0"] != 1 || a["1"] != 2 {
+		t.Errorf("unexpected arities %v", a)
+	}
+	if c := usages[0].Calls[0]; !c.Piped || c.Stage != 1 || c.Pos.Line != 1 {
+		t.Errorf("unexpected call %+v", c)
+	}
+}
@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/check"
+)
+
+// Formats of the template graph.
+const (
+	graphDOT  = "dot"
+	graphJSON = "json"
+)
+
+// runGraph writes the graph of which templates call which across files.
+func runGraph(stdout, stderr io.Writer, stdin io.Reader, args []string) int {
+	log := log.New(stderr, "", 0)
+	fs := flag.FlagSet{}
+	fs.SetOutput(stderr)
+	format := fs.String("format", graphDOT, "output format: dot or json")
+	data := fs.Bool("data", false, "show the data passed to each template call, e.g. .Foo in {{template \"x\" .Foo}}")
+	project := addProjectFlags(&fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, `usage: gtfmt graph [options] [file1] <[file2]...>
+
+Writes the graph of which templates call which, with template and block
+actions, across a set of files parsed together as by ParseFiles or ParseGlob.
+Templates that call each other recursively are marked as cycles, and calls of
+templates that aren't defined as undefined.
+
+Options:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != graphDOT && *format != graphJSON {
+		log.Println("ERROR: ", fmt.Sprintf("unknown graph format %q", *format))
+		return 1
+	}
+	files, err := project.files(fs.Args())
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	g := project.graph()
+	texts, problems, err := loadGraph(g, files)
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	if len(problems) > 0 {
+		printProblems(stderr, files, texts, problems)
+		return 1
+	}
+	if *format == graphJSON {
+		err = writeGraphJSON(stdout, g, texts, *data)
+	} else {
+		err = writeDOT(stdout, g, *data)
+	}
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	return 0
+}
+
+// edge is a call from one template to another, shown once however many
+// times it's made.
+type edge struct {
+	from, to, data string
+}
+
+// edges returns the distinct calls in g, ignoring the data passed unless
+// data is set.
+func edges(g *check.Graph, data bool) []edge {
+	seen := map[edge]bool{}
+	var edges []edge
+	for _, c := range g.Calls {
+		e := edge{from: c.From.Name, to: c.Name}
+		if data {
+			e.data = c.Data
+		}
+		if !seen[e] {
+			seen[e] = true
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+// writeDOT writes g in the Graphviz DOT language. Top level templates are
+// drawn as boxes, undefined templates dashed, and calls within a cycle red.
+func writeDOT(w io.Writer, g *check.Graph, data bool) error {
+	var b strings.Builder
+	b.WriteString("digraph templates {\n")
+	nodes := map[string]bool{}
+	for _, t := range g.Templates {
+		if nodes[t.Name] {
+			continue
+		}
+		nodes[t.Name] = true
+		if t.Top {
+			fmt.Fprintf(&b, "\t%s [shape=box];\n", strconv.Quote(t.Name))
+		} else {
+			fmt.Fprintf(&b, "\t%s;\n", strconv.Quote(t.Name))
+		}
+	}
+	for _, c := range g.Undefined() {
+		if !nodes[c.Name] {
+			nodes[c.Name] = true
+			fmt.Fprintf(&b, "\t%s [style=dashed];\n", strconv.Quote(c.Name))
+		}
+	}
+	cycle := map[string]int{}
+	for i, names := range g.Cycles() {
+		for _, name := range names {
+			cycle[name] = i + 1
+		}
+	}
+	for _, e := range edges(g, data) {
+		var attrs []string
+		if e.data != "" {
+			attrs = append(attrs, "label="+strconv.Quote(e.data))
+		}
+		if n := cycle[e.from]; n != 0 && cycle[e.to] == n {
+			attrs = append(attrs, "color=red")
+		}
+		fmt.Fprintf(&b, "\t%s -> %s", strconv.Quote(e.from), strconv.Quote(e.to))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type jsonGraph struct {
+	Templates []jsonTemplate `json:"templates"`
+	Calls     []jsonCall     `json:"calls"`
+	Undefined []string       `json:"undefined"`
+	Cycles    [][]string     `json:"cycles"`
+}
+
+type jsonTemplate struct {
+	Name  string  `json:"name"`
+	Path  string  `json:"path"`
+	Pos   jsonPos `json:"pos"`
+	Top   bool    `json:"top,omitempty"`
+	Block bool    `json:"block,omitempty"`
+}
+
+type jsonCall struct {
+	From string  `json:"from"`
+	To   string  `json:"to"`
+	Path string  `json:"path"`
+	Pos  jsonPos `json:"pos"`
+	Data string  `json:"data,omitempty"` // only set with -data
+}
+
+// writeGraphJSON writes g as a JSON object, with every call and its position.
+func writeGraphJSON(w io.Writer, g *check.Graph, texts map[string]string, data bool) error {
+	out := jsonGraph{Templates: []jsonTemplate{}, Calls: []jsonCall{}, Undefined: []string{}, Cycles: g.Cycles()}
+	if out.Cycles == nil {
+		out.Cycles = [][]string{}
+	}
+	for _, t := range g.Templates {
+		out.Templates = append(out.Templates, jsonTemplate{
+			Name:  t.Name,
+			Path:  t.File,
+			Pos:   newPos(texts[t.File], int(t.Pos)),
+			Top:   t.Top,
+			Block: t.Block,
+		})
+	}
+	for _, c := range g.Calls {
+		jc := jsonCall{From: c.From.Name, To: c.Name, Path: c.File, Pos: newPos(texts[c.File], int(c.Pos))}
+		if data {
+			jc.Data = c.Data
+		}
+		out.Calls = append(out.Calls, jc)
+	}
+	seen := map[string]bool{}
+	for _, c := range g.Undefined() {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			out.Undefined = append(out.Undefined, c.Name)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
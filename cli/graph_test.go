@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeGraphFiles(t *testing.T) (dir, page, parts string) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page = filepath.Join(dir, "page.tmpl")
+	parts = filepath.Join(dir, "parts.tmpl")
+	for fn, cont := range map[string]string{
+		page:  "{{template \"layout\" .}}\n{{template \"layout\" .Page}}",
+		parts: "{{define \"layout\"}}{{block \"menu\" .Menu}}{{range .}}{{template \"item\" .}}{{end}}{{end}}{{template \"nope\"}}{{end}}\n{{define \"item\"}}{{template \"menu\" .Children}}{{end}}",
+	} {
+		if err := ioutil.WriteFile(fn, []byte(cont), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, page, parts
+}
+
+func TestGraphDOT(t *testing.T) {
+	dir, page, parts := writeGraphFiles(t)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"graph", "-data", page, parts})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	expected := `digraph templates {
+	"page.tmpl" [shape=box];
+	"layout";
+	"menu";
+	"item";
+	"nope" [style=dashed];
+	"page.tmpl" -> "layout" [label="."];
+	"page.tmpl" -> "layout" [label=".Page"];
+	"layout" -> "menu" [label=".Menu"];
+	"menu" -> "item" [label=".", color=red];
+	"layout" -> "nope";
+	"item" -> "menu" [label=".Children", color=red];
+}
+`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestGraphJSON(t *testing.T) {
+	dir, page, parts := writeGraphFiles(t)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"graph", "-format", "json", page, parts})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	var g jsonGraph
+	if err := json.Unmarshal(stdout.Bytes(), &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Templates) != 4 || len(g.Calls) != 6 {
+		t.Fatalf("expected 4 templates and 6 calls but got %+v", g)
+	}
+	expected := jsonCall{From: "layout", To: "menu", Path: parts, Pos: jsonPos{Line: 1, Column: 28, Offset: 27}}
+	if g.Calls[2] != expected {
+		t.Errorf("expected %+v but got %+v", expected, g.Calls[2])
+	}
+	if expected := [][]string{{"item", "menu"}}; !reflect.DeepEqual(g.Cycles, expected) {
+		t.Errorf("expected cycles %q but got %q", expected, g.Cycles)
+	}
+	if expected := []string{"nope"}; !reflect.DeepEqual(g.Undefined, expected) {
+		t.Errorf("expected undefined %q but got %q", expected, g.Undefined)
+	}
+}
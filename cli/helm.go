@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// chartExts are the extensions of the files Helm renders as templates.
+var chartExts = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+	".tpl":  true,
+	".txt":  true,
+}
+
+// chartFiles returns the template files of the Helm charts in dirs, or in the
+// current directory if there are none: the files in the templates directory
+// of each chart, including the charts it vendors in its charts directory.
+func chartFiles(dirs []string) ([]string, error) {
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var files []string
+	for _, dir := range dirs {
+		found := false
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() || info.Name() != "templates" {
+				return nil
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(path), "Chart.yaml")); err != nil {
+				return nil
+			}
+			found = true
+			err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() && chartExts[filepath.Ext(path)] {
+					files = append(files, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("no Helm chart found in %s", dir)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeChart writes a Helm chart with a subchart to a new directory.
+func writeChart(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"Chart.yaml":                   "name: app\n",
+		"values.yaml":                  "x: {{  not a template  }}\n",
+		"templates/_helpers.tpl":       "{{- define \"app.name\" -}}\n{{  .Chart.Name  }}\n{{- end }}\n{{- define \"app.old\" -}}\nold\n{{- end }}\n",
+		"templates/deployment.yaml":    "metadata:\n  name: {{ include  \"app.name\"  . }}\n  labels:\n    {{- toYaml  .Values.labels | nindent 4 }}\n",
+		"templates/NOTES.txt":          "Installed {{  .Release.Name  }}.\n",
+		"charts/sub/Chart.yaml":        "name: sub\n",
+		"charts/sub/templates/cm.yaml": "data: {{  upper  .Values.y }}\n",
+		"charts/sub/templates/README":  "{{  ignored  }}\n",
+	}
+	for name, text := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFmtHelm(t *testing.T) {
+	dir := writeChart(t)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-helm", dir})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+	expected := map[string]string{
+		"values.yaml":                  "x: {{  not a template  }}\n",
+		"templates/_helpers.tpl":       "{{- define \"app.name\" -}}\n{{.Chart.Name}}\n{{- end}}\n{{- define \"app.old\" -}}\nold\n{{- end}}\n",
+		"templates/deployment.yaml":    "metadata:\n  name: {{include \"app.name\" .}}\n  labels:\n    {{- toYaml .Values.labels | nindent 4}}\n",
+		"templates/NOTES.txt":          "Installed {{.Release.Name}}.\n",
+		"charts/sub/templates/cm.yaml": "data: {{upper .Values.y}}\n",
+		"charts/sub/templates/README":  "{{  ignored  }}\n",
+	}
+	for name, text := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != text {
+			t.Errorf("%s: expected:\n%s\nbut got:\n%s", name, text, b)
+		}
+	}
+}
+
+func TestDefsHelm(t *testing.T) {
+	dir := writeChart(t)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"defs", "-helm", dir})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := filepath.Join(dir, "templates/_helpers.tpl") + ":4:12: template \"app.old\" defined and not used\n"
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestVetHelm(t *testing.T) {
+	dir := writeChart(t)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"vet", "-helm", dir})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	if s := stdout.String(); s != "" {
+		t.Errorf("Expected no stdout but got %q", s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestDefsHelmSubchart(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app/Chart.yaml":                           "name: app\n",
+		"app/templates/deployment.yaml":            "name: {{ .Chart.Name }}\n",
+		"app/charts/sub/Chart.yaml":                "name: sub\n",
+		"app/charts/sub/templates/deployment.yaml": "name: {{ .Chart.Name }}-sub\n",
+	})
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"defs", "-helm", dir})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d: %s", code, stdout.String())
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+
+	stdout.Reset()
+	code = ParseAndRun(&stdout, &stderr, nil, []string{"graph", "-helm", dir})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	expected := `digraph templates {
+	"sub/templates/deployment.yaml" [shape=box];
+	"app/templates/deployment.yaml" [shape=box];
+}
+`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+}
+
+func TestHelmNoChart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"graph", "-helm", dir})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := "ERROR:  no Helm chart found in " + dir + "\n"
+	if s := stderr.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+}
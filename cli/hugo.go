@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// layoutFiles returns the layout templates of the Hugo sites or themes in
+// dirs, or in the current directory if there are none, following Hugo's
+// lookup: the files in the layouts directory of each, then those in the
+// layouts directories of its themes that the site's own layouts don't
+// override.
+func layoutFiles(dirs []string) ([]string, error) {
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var files []string
+	for _, dir := range dirs {
+		roots := []string{filepath.Join(dir, "layouts")}
+		themes, err := filepath.Glob(filepath.Join(dir, "themes", "*", "layouts"))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, themes...)
+		found := false
+		seen := map[string]bool{} // paths in the layouts directories
+		for _, root := range roots {
+			if info, err := os.Stat(root); err != nil || !info.IsDir() {
+				continue
+			}
+			found = true
+			err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if strings.HasPrefix(info.Name(), ".") && path != root {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if info.IsDir() {
+					return nil
+				}
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					return err
+				}
+				if !seen[rel] {
+					seen[rel] = true
+					files = append(files, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no Hugo layouts found in %s", dir)
+		}
+	}
+	return files, nil
+}
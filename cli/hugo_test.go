@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeSite writes a Hugo site with a theme to a new directory.
+func writeSite(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"config.toml":                           "theme = \"t\"\n",
+		"layouts/_default/single.html":          "{{- /* the page */ -}}\n{{ define  \"main\" }}{{  .Scratch.Set  \"x\" 1 }}{{ partial  \"nav\" . }}{{ end }}\n",
+		"layouts/.hidden.html":                  "{{  x  }}\n",
+		"themes/t/layouts/_default/baseof.html": "{{ block  \"main\"  . }}{{ end }}\n",
+		"themes/t/layouts/_default/single.html": "overridden\n",
+		"themes/t/layouts/_default/list.html":   "{{ define \"main\" }}{{ range collections.Where  .Pages  \"Type\" \"post\" }}{{ strings.Title  .Title }}{{ end }}{{ end }}\n",
+		"themes/t/layouts/partials/nav.html":    "{{ partialCached \"old\" . }}\n",
+		"themes/t/layouts/partials/unused.html": "u\n",
+		"themes/t/layouts/partials/old.html":    "o\n",
+	}
+	for name, text := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLayoutFiles(t *testing.T) {
+	dir := writeSite(t)
+	defer os.RemoveAll(dir)
+	files, err := layoutFiles([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []string
+	for _, name := range []string{
+		"layouts/_default/single.html",
+		"themes/t/layouts/_default/baseof.html",
+		"themes/t/layouts/_default/list.html",
+		"themes/t/layouts/partials/nav.html",
+		"themes/t/layouts/partials/old.html",
+		"themes/t/layouts/partials/unused.html",
+	} {
+		expected = append(expected, filepath.Join(dir, name))
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected:\n%q\nbut got:\n%q", expected, files)
+	}
+}
+
+func TestFmtHugo(t *testing.T) {
+	dir := writeSite(t)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-hugo", dir})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+	expected := map[string]string{
+		"layouts/_default/single.html":          "{{- /* the page */ -}}\n{{define \"main\"}}{{.Scratch.Set \"x\" 1}}{{partial \"nav\" .}}{{end}}\n",
+		"layouts/.hidden.html":                  "{{  x  }}\n",
+		"themes/t/layouts/_default/single.html": "overridden\n",
+		"themes/t/layouts/_default/list.html":   "{{define \"main\"}}{{range collections.Where .Pages \"Type\" \"post\"}}{{strings.Title .Title}}{{end}}{{end}}\n",
+	}
+	for name, text := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != text {
+			t.Errorf("%s: expected:\n%s\nbut got:\n%s", name, text, b)
+		}
+	}
+}
+
+func TestDefsHugo(t *testing.T) {
+	dir := writeSite(t)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"defs", "-hugo", dir})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := filepath.Join(dir, "themes/t/layouts/partials/unused.html") + ":1:1: template \"partials/unused.html\" defined and not used\n"
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestVetHugo(t *testing.T) {
+	dir := writeSite(t)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"vet", "-hugo", dir})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	if s := stdout.String(); s != "" {
+		t.Errorf("Expected no stdout but got %q", s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
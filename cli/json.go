@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gotpl/gtfmt/gtfmt"
+)
+
+// Statuses reported for each file with -json.
+const (
+	statusFormatted = "formatted" // the file needed no changes
+	statusChanged   = "changed"   // the file was (or with -l, would be) changed
+	statusError     = "error"     // the file could not be formatted
+)
+
+// fileResult is the JSON object reported for each file with -json.
+type fileResult struct {
+	Path    string     `json:"path"`
+	Status  string     `json:"status"`
+	Error   *jsonError `json:"error,omitempty"`
+	Matches *int       `json:"matches,omitempty"` // rewrite matches, only set with -r
+	Edits   []jsonEdit `json:"edits,omitempty"`
+
+	text string // the original text of the file
+}
+
+type jsonError struct {
+	Message string   `json:"message"`
+	Pos     *jsonPos `json:"pos,omitempty"`
+	Snippet string   `json:"snippet,omitempty"` // the line in error, with a caret under the error
+}
+
+// jsonPos is a position in a file. Line and Column are 1-based; Column counts
+// bytes.
+type jsonPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+type jsonEdit struct {
+	Start   jsonPos `json:"start"`
+	End     jsonPos `json:"end"`
+	NewText string  `json:"newText"`
+}
+
+// newPos returns the position of the byte at offset in text.
+func newPos(text string, offset int) jsonPos {
+	before := text[:offset]
+	return jsonPos{
+		Line:   1 + strings.Count(before, "\n"),
+		Column: offset - strings.LastIndex(before, "\n"),
+		Offset: offset,
+	}
+}
+
+// runJSON formats or rewrites each file, reporting the result for each as a
+// JSON object on stdout.
+func (c *Command) runJSON() error {
+	enc := json.NewEncoder(c.Stdout)
+	return c.report(func(res *fileResult) error {
+		return enc.Encode(res)
+	})
+}
+
+// report formats or rewrites each file, or stdin if there are none, passing
+// the result for each to fn. Unlike the plain output, an error in one file
+// does not stop the others from being processed.
+func (c *Command) report(fn func(*fileResult) error) error {
+	if len(c.Files) == 0 {
+		b, err := ioutil.ReadAll(c.Stdin)
+		if err != nil {
+			return err
+		}
+		res, _ := c.result("stdin", string(b), c.Lines)
+		if err := fn(res); err != nil {
+			return err
+		}
+		if res.Status == statusError {
+			return fmt.Errorf("stdin: %s", res.Error.Message)
+		}
+		return nil
+	}
+	failed := 0
+	for _, file := range c.Files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		orig := string(b)
+		lines := c.Lines
+		if c.DiffBase != "" {
+			lines, err = changedLines(c.DiffBase, file)
+			if err != nil {
+				return err
+			}
+		}
+		var res *fileResult
+		var s string
+		if c.DiffBase != "" && len(lines) == 0 {
+			res = &fileResult{Path: file, Status: statusFormatted, text: orig}
+		} else {
+			res, s = c.result(file, orig, lines)
+		}
+		if err := fn(res); err != nil {
+			return err
+		}
+		switch {
+		case res.Status == statusError:
+			failed++
+		case res.Status == statusChanged && !c.List:
+			info, err := os.Stat(file)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(file, []byte(s), info.Mode()); err != nil {
+				return err
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files could not be formatted", failed, len(c.Files))
+	}
+	return nil
+}
+
+// result formats or rewrites the named template, returning its result along
+// with the new text.
+func (c *Command) result(name, tpl string, lines []gtfmt.LineRange) (*fileResult, string) {
+	res := &fileResult{Path: name, text: tpl}
+	var edits []gtfmt.TextEdit
+	var err error
+	if c.Orig != "" {
+		var n int
+		edits, n, err = c.rewriteEdits(name, tpl)
+		res.Matches = &n
+	} else {
+		edits, err = c.formatEdits(name, tpl, lines)
+	}
+	var s string
+	if err == nil {
+		s, err = gtfmt.Apply(tpl, edits)
+	}
+	if err != nil {
+		res.Status = statusError
+		res.Matches = nil
+		res.Error = &jsonError{Message: err.Error()}
+		switch errs := err.(type) {
+		case gtfmt.BlockErrors:
+			err = errs[0]
+		case gtfmt.ErrorList:
+			err = errs[0]
+		}
+		if gerr, ok := err.(*gtfmt.Error); ok {
+			res.Error.Message = gerr.Msg
+			res.Error.Pos = &jsonPos{Line: gerr.Line, Column: gerr.Column, Offset: gerr.Offset}
+			res.Error.Snippet = gerr.Snippet
+		}
+		return res, ""
+	}
+	if s == tpl {
+		res.Status = statusFormatted
+		return res, s
+	}
+	res.Status = statusChanged
+	for _, e := range edits {
+		res.Edits = append(res.Edits, jsonEdit{
+			Start:   newPos(tpl, e.Start),
+			End:     newPos(tpl, e.End),
+			NewText: e.NewText,
+		})
+	}
+	return res, s
+}
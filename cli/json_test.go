@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func decodeResults(t *testing.T, b []byte) []fileResult {
+	var results []fileResult
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var res fileResult
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func TestJSONFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f1 := filepath.Join(dir, "foo")
+	f2 := filepath.Join(dir, "foo2")
+	f3 := filepath.Join(dir, "foo3")
+	cont1 := []byte("a\n{{  .A  }}\n")
+	for fn, cont := range map[string][]byte{
+		f1: cont1,
+		f2: []byte(`{{.A}}`),
+		f3: []byte("ok\n  {{.A ) }}"),
+	} {
+		if err := ioutil.WriteFile(fn, cont, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-json", "-l", f1, f2, f3})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := []fileResult{
+		{Path: f1, Status: statusChanged, Edits: []jsonEdit{{
+			Start:   jsonPos{Line: 2, Column: 1, Offset: 2},
+			End:     jsonPos{Line: 2, Column: 11, Offset: 12},
+			NewText: "{{.A}}",
+		}}},
+		{Path: f2, Status: statusFormatted},
+		{Path: f3, Status: statusError, Error: &jsonError{
+			Message: `unexpected ")" in input`,
+			Pos:     &jsonPos{Line: 2, Column: 8, Offset: 10},
+			Snippet: "  {{.A ) }}\n       ^",
+		}},
+	}
+	if results := decodeResults(t, stdout.Bytes()); !reflect.DeepEqual(expected, results) {
+		t.Errorf("expected:\n%+v\n\ngot:\n%+v", expected, results)
+	}
+	if s := stderr.String(); s != "ERROR:  1 of 3 files could not be formatted\n" {
+		t.Errorf("unexpected stderr %q", s)
+	}
+	b, err := ioutil.ReadFile(f1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, cont1) {
+		t.Error("contents of unformatted file were changed but should not have been")
+	}
+}
+
+func TestJSONReplaceStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	stdin := bytes.NewBufferString(`{{index "index" "d"}}{{index .}}`)
+	code := ParseAndRun(&stdout, &stderr, stdin, []string{"-json", "-r", "index -> strings.Index"})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	matches := 2
+	expected := []fileResult{{
+		Path:    "stdin",
+		Status:  statusChanged,
+		Matches: &matches,
+		Edits: []jsonEdit{
+			{
+				Start:   jsonPos{Line: 1, Column: 1, Offset: 0},
+				End:     jsonPos{Line: 1, Column: 22, Offset: 21},
+				NewText: `{{strings.Index "index" "d"}}`,
+			},
+			{
+				Start:   jsonPos{Line: 1, Column: 22, Offset: 21},
+				End:     jsonPos{Line: 1, Column: 33, Offset: 32},
+				NewText: `{{strings.Index .}}`,
+			},
+		},
+	}}
+	if results := decodeResults(t, stdout.Bytes()); !reflect.DeepEqual(expected, results) {
+		t.Errorf("expected:\n%+v\n\ngot:\n%+v", expected, results)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestJSONReplaceGoFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "tmpl.go")
+	if err := ioutil.WriteFile(fn, []byte("package tmpl\n\nconst t = `{{index .A 1}}`\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-json", "-r", "index -> strings.Index", fn})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := []fileResult{{Path: fn, Status: statusError, Error: &jsonError{
+		Message: fn + ": rewrite rules are not supported for Go files",
+	}}}
+	if results := decodeResults(t, stdout.Bytes()); !reflect.DeepEqual(expected, results) {
+		t.Errorf("expected:\n%+v\n\ngot:\n%+v", expected, results)
+	}
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/gotpl/gtfmt/internal/lsp"
+)
+
+// runLSP runs a language server over stdin and stdout.
+func runLSP(stdout, stderr io.Writer, stdin io.Reader, args []string) int {
+	fs := flag.FlagSet{}
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, `usage: gtfmt lsp
+
+Runs a Language Server Protocol server for go templates on stdin and stdout.`)
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := lsp.Serve(stdin, stdout); err != nil {
+		log.New(stderr, "", 0).Println("ERROR: ", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/gotpl/gtfmt/internal/check"
+)
+
+// projectFlags are the flags of the commands that read a set of files for the
+// kind of project the templates come from.
+type projectFlags struct {
+	helm bool // if true, the arguments are Helm chart directories
+	hugo bool // if true, the arguments are Hugo site or theme directories
+}
+
+// addProjectFlags adds the -helm and -hugo flags to fs.
+func addProjectFlags(fs *flag.FlagSet) *projectFlags {
+	p := &projectFlags{}
+	fs.BoolVar(&p.helm, "helm", false, "read the templates of the Helm charts in the given directories (default .), counting calls of include as template calls")
+	fs.BoolVar(&p.hugo, "hugo", false, "read the layouts of the Hugo sites or themes in the given directories (default .), counting calls of partial as template calls")
+	return p
+}
+
+// files returns the files to read for the arguments args: the files given,
+// or the templates of the charts or sites in the directories given.
+func (p *projectFlags) files(args []string) ([]string, error) {
+	switch {
+	case p.helm && p.hugo:
+		return nil, errors.New("-helm may not be used with -hugo")
+	case p.helm:
+		return chartFiles(args)
+	case p.hugo:
+		return layoutFiles(args)
+	}
+	if len(args) == 0 {
+		return nil, errors.New("no files given")
+	}
+	return args, nil
+}
+
+// graph returns an empty graph of templates that follows the conventions of
+// the project.
+func (p *projectFlags) graph() *check.Graph {
+	switch {
+	case p.helm:
+		return &check.Graph{CallFuncs: check.HelmCallFuncs, TopName: check.HelmTopName}
+	case p.hugo:
+		return &check.Graph{
+			CallFuncs: check.HugoCallFuncs,
+			TopName:   check.HugoTopName,
+			External:  check.HugoExternal,
+		}
+	}
+	return &check.Graph{}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gotpl/gtfmt/internal/sarif"
+)
+
+// Output formats for -format.
+const (
+	formatJSON  = "json"
+	formatSARIF = "sarif"
+)
+
+// SARIF rule IDs.
+const (
+	ruleUnformatted = "unformatted"
+	ruleParseError  = "parse-error"
+)
+
+// runSARIF formats or rewrites each file, writing a SARIF log of the files
+// that need changes and the files that fail to parse to stdout.
+func (c *Command) runSARIF() error {
+	log := sarif.New("gtfmt", "https://github.com/gotpl/gtfmt")
+	log.AddRule(ruleUnformatted, "template is not formatted")
+	log.AddRule(ruleParseError, "template does not parse")
+	err := c.report(func(res *fileResult) error {
+		switch res.Status {
+		case statusChanged:
+			msg := "template is not formatted"
+			if res.Matches != nil {
+				msg = "template matches rewrite rule"
+			}
+			e := res.Edits[0]
+			log.Add(ruleUnformatted, sarif.Warning, msg, res.Path, &sarif.Region{
+				StartLine:   e.Start.Line,
+				StartColumn: runeColumn(res.text, e.Start),
+				EndLine:     e.End.Line,
+				EndColumn:   runeColumn(res.text, e.End),
+			})
+		case statusError:
+			var region *sarif.Region
+			if pos := res.Error.Pos; pos != nil {
+				region = &sarif.Region{StartLine: pos.Line, StartColumn: runeColumn(res.text, *pos)}
+			}
+			log.Add(ruleParseError, sarif.Error, res.Error.Message, res.Path, region)
+		}
+		return nil
+	})
+	if werr := log.Write(c.Stdout); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// runeColumn returns the 1-based column of pos in text, counted in Unicode code
+// points.
+func runeColumn(text string, pos jsonPos) int {
+	start := strings.LastIndex(text[:pos.Offset], "\n") + 1
+	return 1 + utf8.RuneCountInString(text[start:pos.Offset])
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gotpl/gtfmt/internal/sarif"
+)
+
+func TestSARIF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f1 := filepath.Join(dir, "foo")
+	f2 := filepath.Join(dir, "foo2")
+	f3 := filepath.Join(dir, "foo3")
+	for fn, cont := range map[string]string{
+		f1: "ok\né {{  .A  }}\n{{  .B  }}\n",
+		f2: `{{.A}}`,
+		f3: "ok\n  {{.A ) }}",
+	} {
+		if err := ioutil.WriteFile(fn, []byte(cont), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"-format", "sarif", "-l", f1, f2, f3})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	var log sarif.Log
+	if err := json.Unmarshal(stdout.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(log.Runs))
+	}
+	expected := []*sarif.Result{
+		{
+			RuleID:  ruleUnformatted,
+			Level:   sarif.Warning,
+			Message: sarif.Message{Text: "template is not formatted"},
+			Locations: []sarif.Location{{PhysicalLocation: sarif.PhysicalLocation{
+				ArtifactLocation: sarif.ArtifactLocation{URI: f1},
+				Region:           &sarif.Region{StartLine: 2, StartColumn: 3, EndLine: 2, EndColumn: 13},
+			}}},
+		},
+		{
+			RuleID:  ruleParseError,
+			Level:   sarif.Error,
+			Message: sarif.Message{Text: `unexpected ")" in input`},
+			Locations: []sarif.Location{{PhysicalLocation: sarif.PhysicalLocation{
+				ArtifactLocation: sarif.ArtifactLocation{URI: f3},
+				Region:           &sarif.Region{StartLine: 2, StartColumn: 8},
+			}}},
+		},
+	}
+	if !reflect.DeepEqual(expected, log.Runs[0].Results) {
+		t.Errorf("expected:\n%s\n\ngot:\n%s", mustJSON(t, expected), mustJSON(t, log.Runs[0].Results))
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	c, err := Parse(stdout, []string{"-json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Format != formatJSON {
+		t.Errorf("expected -json to set format %q, got %q", formatJSON, c.Format)
+	}
+	if _, err := Parse(stdout, []string{"-format", "xml"}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/check"
+	"github.com/gotpl/gtfmt/internal/config"
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// errFindings is returned by Vet.Run when it reported problems.
+var errFindings = errors.New("problems found")
+
+// runVet parses the vet command line and runs it.
+func runVet(stdout, stderr io.Writer, stdin io.Reader, args []string) int {
+	log := log.New(stderr, "", 0)
+	v, err := ParseVet(stderr, args)
+	if err == flag.ErrHelp {
+		return 2
+	}
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	v.Stdout = stdout
+	v.Stdin = stdin
+	err = v.Run()
+	if err == errFindings {
+		return 1
+	}
+	if err != nil {
+		log.Println("ERROR: ", err)
+		return 1
+	}
+	return 0
+}
+
+// ParseVet parses the arguments to the vet command.
+func ParseVet(stderr io.Writer, args []string) (*Vet, error) {
+	fs := flag.FlagSet{}
+	fs.SetOutput(stderr)
+	var funcs, presets, goDirs, data string
+	fs.StringVar(&funcs, "funcs", "", "file listing the allowed functions, one per line")
+	fs.StringVar(&presets, "preset", "", "allow the functions of a preset: "+strings.Join(check.Presets(), ", ")+" (comma separated)")
+	fs.StringVar(&goDirs, "go", "", "allow the FuncMap keys declared in these Go package directories e.g. './...' (comma separated)")
+	fs.StringVar(&data, "data", "", "check field accesses against the Go type of the data passed in e.g. './models.Page'")
+	html := fs.Bool("html", false, "check for patterns that are risky in html/template")
+	fix := fs.Bool("fix", false, "delete actions declaring unused variables where that can't change how the template executes")
+	helm := fs.Bool("helm", false, "vet the templates of the Helm charts in the given directories (default .), allowing the helm preset")
+	hugo := fs.Bool("hugo", false, "vet the layouts of the Hugo sites or themes in the given directories (default .), allowing the hugo preset")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, `usage: gtfmt vet [options] [file1] <[file2]...>
+
+Reports problems in one or more go templates. If not given a filename, will read from stdin.
+
+Calls of functions that are neither text/template builtins nor allowed by
+-funcs, -preset or -go are reported. With -go, FuncMap entries that none of the
+templates use are reported too.
+
+With -data, field and method accesses such as .Foo.Bar that don't exist on the
+type of the data executing each file are reported.
+
+Variables that are declared but never used, and declarations in an if, with or
+range that shadow a variable of an outer scope, are always reported. With -fix,
+actions that only declare an unused variable are deleted when that is safe.
+
+With -helm, the arguments are Helm chart directories, and the templates of
+each chart are vetted with the functions of the helm preset allowed. Likewise
+with -hugo, the arguments are Hugo site or theme directories, and their layouts
+are vetted with the functions of the hugo preset allowed.
+
+With -html, the HTML context is followed through the text of each file as
+html/template does, and actions in unquoted attribute values, functions such as
+safeJS called in JavaScript, and branches that end in different contexts are
+reported.
+
+Options:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	v := &Vet{Funcs: check.NewFuncSet(), HTML: *html, Fix: *fix, Files: fs.Args()}
+	if *helm || *hugo {
+		project := &projectFlags{helm: *helm, hugo: *hugo}
+		files, err := project.files(v.Files)
+		if err != nil {
+			return nil, err
+		}
+		v.Files = files
+		preset := "helm"
+		if *hugo {
+			preset = "hugo"
+		}
+		if err := v.Funcs.AddPreset(preset); err != nil {
+			return nil, err
+		}
+	}
+	if v.Fix && len(v.Files) == 0 {
+		return nil, errors.New("-fix requires at least one file")
+	}
+	if funcs != "" {
+		f, err := os.Open(funcs)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		names, err := check.ReadFuncs(f)
+		if err != nil {
+			return nil, err
+		}
+		v.Funcs.Add(names...)
+	}
+	if presets != "" {
+		for _, name := range strings.Split(presets, ",") {
+			if err := v.Funcs.AddPreset(strings.TrimSpace(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if data != "" {
+		t, err := check.LoadType(data)
+		if err != nil {
+			return nil, err
+		}
+		v.Data = t
+	}
+	if goDirs != "" {
+		gofuncs, err := check.GoFuncs(strings.Split(goDirs, ",")...)
+		if err != nil {
+			return nil, err
+		}
+		v.GoFuncs = gofuncs
+		for _, f := range gofuncs {
+			v.Funcs.Add(f.Name)
+		}
+	}
+	return v, nil
+}
+
+// Vet is the vet command to run.
+type Vet struct {
+	Funcs   check.FuncSet  // functions templates may call
+	GoFuncs []check.GoFunc // if set, report those no template calls
+	Data    types.Type     // if set, the type of the data each file is executed with
+	HTML    bool           // if true, check for risky html/template patterns
+	Fix     bool           // if true, delete unused variables before checking
+	Files   []string
+	Stdout  io.Writer
+	Stdin   io.Reader
+
+	used    map[string]bool // functions called by the templates vetted
+	invalid bool            // if true, a template could not be parsed
+	configs config.Loader   // the configs of the files' directories
+	funcs   map[*config.Config]check.FuncSet
+}
+
+// Run checks each file, printing any problems found. It returns errFindings if
+// there were any.
+func (v *Vet) Run() error {
+	v.used = map[string]bool{}
+	found := false
+	if len(v.Files) == 0 {
+		b, err := ioutil.ReadAll(v.Stdin)
+		if err != nil {
+			return err
+		}
+		if found, err = v.vet("stdin", string(b)); err != nil {
+			return err
+		}
+	}
+	files, err := expandFiles(&v.configs, v.Files)
+	if err != nil {
+		return err
+	}
+	for _, fn := range files {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		text := string(b)
+		if v.Fix {
+			if text, err = v.fix(fn, text); err != nil {
+				return err
+			}
+		}
+		problems, err := v.vet(fn, text)
+		if err != nil {
+			return err
+		}
+		if problems {
+			found = true
+		}
+	}
+	// Unused functions can't be known if a template could not be parsed.
+	if len(v.GoFuncs) > 0 && !v.invalid {
+		for _, f := range check.UnusedFuncs(v.GoFuncs, v.used) {
+			fmt.Fprintf(v.Stdout, "%s: function %q in FuncMap not used by any template\n", f.Pos, f.Name)
+			found = true
+		}
+	}
+	if found {
+		return errFindings
+	}
+	return nil
+}
+
+// vet checks a single template, following the config of its directory,
+// reporting whether it found any problems.
+func (v *Vet) vet(name, text string) (bool, error) {
+	cfg, err := configFor(&v.configs, name)
+	if err != nil {
+		return false, err
+	}
+	funcs, err := v.funcSet(cfg)
+	if err != nil {
+		return false, err
+	}
+	trees, err := parse.ParseNoFuncs(name, text, cfg.LeftDelim, cfg.RightDelim)
+	if err != nil {
+		v.invalid = true
+		if perr, ok := err.(*parse.Error); ok {
+			v.report(name, text, check.Finding{Pos: perr.Pos, Msg: perr.Msg})
+		} else {
+			fmt.Fprintf(v.Stdout, "%s: %v\n", name, err)
+		}
+		return true, nil
+	}
+	check.UsedFuncs(trees, v.used)
+	findings := check.UndefinedFuncs(trees, funcs)
+	findings = append(findings, check.Vars(trees)...)
+	if v.Data != nil {
+		findings = append(findings, check.Fields(trees, name, v.Data)...)
+	}
+	if v.HTML {
+		findings = append(findings, check.HTML(trees)...)
+	}
+	check.SortFindings(findings)
+	for _, f := range findings {
+		v.report(name, text, f)
+	}
+	return len(findings) > 0, nil
+}
+
+// funcSet returns the functions templates following cfg may call: those
+// allowed on the command line, and by the config.
+func (v *Vet) funcSet(cfg *config.Config) (check.FuncSet, error) {
+	if len(cfg.Funcs) == 0 && len(cfg.Presets) == 0 {
+		return v.Funcs, nil
+	}
+	if funcs, ok := v.funcs[cfg]; ok {
+		return funcs, nil
+	}
+	funcs := check.NewFuncSet(cfg.Funcs...)
+	for name := range v.Funcs {
+		funcs.Add(name)
+	}
+	for _, name := range cfg.Presets {
+		if err := funcs.AddPreset(name); err != nil {
+			return nil, err
+		}
+	}
+	if v.funcs == nil {
+		v.funcs = map[*config.Config]check.FuncSet{}
+	}
+	v.funcs[cfg] = funcs
+	return funcs, nil
+}
+
+// fix deletes the unused variables in the file fn with the given text, with
+// the delimiters set by the config of its directory, returning the new text.
+// Templates that don't parse are left for vet to report.
+func (v *Vet) fix(fn, text string) (string, error) {
+	cfg, err := configFor(&v.configs, fn)
+	if err != nil {
+		return "", err
+	}
+	s, n, err := check.RemoveUnusedVars(fn, text, cfg.LeftDelim, cfg.RightDelim)
+	if err != nil || n == 0 {
+		return text, nil
+	}
+	info, err := os.Stat(fn)
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(fn, []byte(s), info.Mode()); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+func (v *Vet) report(name, text string, f check.Finding) {
+	pos := newPos(text, int(f.Pos))
+	fmt.Fprintf(v.Stdout, "%s:%d:%d: %s\n", name, pos.Line, pos.Column, f.Msg)
+}
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	funcs := filepath.Join(dir, "funcs")
+	tpl := filepath.Join(dir, "tpl")
+	bad := filepath.Join(dir, "bad")
+	for fn, cont := range map[string]string{
+		funcs: "# allowed\nmyfunc\n",
+		tpl:   "{{myfunc .}}\n{{tittle .Name}} {{upper .X}}\n{{define \"x\"}}{{lowr .}}{{end}}",
+		bad:   "{{.X",
+	} {
+		if err := ioutil.WriteFile(fn, []byte(cont), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"vet", "-funcs", funcs, "-preset", "sprig", tpl, bad})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := tpl + `:2:3: function "tittle" not defined
+` + tpl + `:3:17: function "lowr" not defined
+` + bad + `:1:5: unclosed action
+`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestVetStdinClean(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	stdin := bytes.NewBufferString(`{{printf "%s" (index . 1)}}`)
+	code := ParseAndRun(&stdout, &stderr, stdin, []string{"vet"})
+	if code != 0 {
+		t.Errorf("expected code 0 but got %d", code)
+	}
+	if s := stdout.String(); s != "" {
+		t.Errorf("Expected no stdout but got %q", s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestVetGoFuncs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gofile := filepath.Join(dir, "funcs.go")
+	tpl := filepath.Join(dir, "tpl")
+	for fn, cont := range map[string]string{
+		gofile: "package funcs\n\nimport \"text/template\"\n\nvar funcs = template.FuncMap{\n\t\"myfunc\": nil,\n\t\"unused\": nil,\n}\n",
+		tpl:    "{{myfunc .}} {{other .}}",
+	} {
+		if err := ioutil.WriteFile(fn, []byte(cont), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"vet", "-go", dir, tpl})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := tpl + `:1:16: function "other" not defined
+` + gofile + `:7:2: function "unused" in FuncMap not used by any template
+`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestVetData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gofile := filepath.Join(dir, "models.go")
+	tpl := filepath.Join(dir, "tpl")
+	for fn, cont := range map[string]string{
+		gofile: "package models\n\ntype Page struct {\n\tTitle string\n}\n",
+		tpl:    "{{.Title}}\n{{upper .Titel}}",
+	} {
+		if err := ioutil.WriteFile(fn, []byte(cont), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"vet", "-data", dir + ".Page", tpl})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := tpl + `:2:3: function "upper" not defined
+` + tpl + `:2:9: can't evaluate field Titel in type models.Page
+`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
+
+func TestVetFix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tpl := filepath.Join(dir, "tpl")
+	orig := "{{$a := .}}{{$b := $a}}\n{{range $x := .L}}{{if .}}{{$x := 1}}{{$x}}{{end}}{{end}}\n{{$c := len .C}}"
+	if err := ioutil.WriteFile(tpl, []byte(orig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := ParseAndRun(&stdout, &stderr, nil, []string{"vet", "-fix", tpl})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := tpl + `:2:9: variable $x declared and not used
+` + tpl + `:2:29: declaration of $x shadows declaration at line 2
+` + tpl + `:3:3: variable $c declared and not used
+`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+	b, err := ioutil.ReadFile(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFile := "\n{{range $x := .L}}{{if .}}{{$x := 1}}{{$x}}{{end}}{{end}}\n{{$c := len .C}}"
+	if s := string(b); s != expectedFile {
+		t.Errorf("expected:\n%q\nbut got:\n%q", expectedFile, s)
+	}
+}
+
+func TestVetHTML(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	stdin := bytes.NewBufferString("<img src={{.Src}}>\n{{if .A}}<b>{{else}}<b{{end}}>")
+	code := ParseAndRun(&stdout, &stderr, stdin, []string{"vet", "-html"})
+	if code != 1 {
+		t.Errorf("expected code 1 but got %d", code)
+	}
+	expected := `stdin:1:12: action in unquoted attribute value
+stdin:2:6: if branches end in different contexts: HTML text and a tag
+`
+	if s := stdout.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if s := stderr.String(); s != "" {
+		t.Errorf("Expected no stderr but got %q", s)
+	}
+}
@@ -0,0 +1,141 @@
+package gtfmt
+
+import (
+	"strings"
+)
+
+// TextEdit replaces the bytes [Start, End) of a template with NewText.
+type TextEdit struct {
+	Start   int
+	End     int
+	NewText string
+}
+
+// Diff returns the edits that turn orig into formatted, one for each run of
+// changed lines.
+func Diff(orig, formatted string) []TextEdit {
+	a, b := splitLines(orig), splitLines(formatted)
+	var edits []TextEdit
+	i, j, off := 0, 0, 0
+	for _, m := range append(matchLines(a, b), [2]int{len(a), len(b)}) {
+		if m[0] > i || m[1] > j {
+			start := off
+			for ; i < m[0]; i++ {
+				off += len(a[i])
+			}
+			edits = append(edits, TextEdit{Start: start, End: off, NewText: strings.Join(b[j:m[1]], "")})
+		}
+		if m[0] < len(a) {
+			off += len(a[m[0]])
+		}
+		i, j = m[0]+1, m[1]+1
+	}
+	return edits
+}
+
+// splitLines splits s after each newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines returns the index pairs of the lines in a and b that are part of
+// a shortest edit script between them, in order. It uses the linear space
+// variant of Myers' diff algorithm, which finds the middle snake of the edit
+// script and recurses on either side of it.
+func matchLines(a, b []string) [][2]int {
+	d := &differ{a: a, b: b}
+	d.diff(0, len(a), 0, len(b))
+	return d.matches
+}
+
+// differ finds the matching lines of a and b.
+type differ struct {
+	a, b    []string
+	matches [][2]int
+}
+
+// diff appends the matches between a[x0:x1] and b[y0:y1].
+func (d *differ) diff(x0, x1, y0, y1 int) {
+	for x0 < x1 && y0 < y1 && d.a[x0] == d.b[y0] {
+		d.matches = append(d.matches, [2]int{x0, y0})
+		x0++
+		y0++
+	}
+	suffix := 0
+	for x1 > x0 && y1 > y0 && d.a[x1-1] == d.b[y1-1] {
+		x1--
+		y1--
+		suffix++
+	}
+	// With the common prefix and suffix removed, an edit script between
+	// two non-empty sequences has at least two edits, so the middle snake
+	// splits it into two shorter ones.
+	if x0 < x1 && y0 < y1 {
+		x, y, u, v := d.middleSnake(x0, x1, y0, y1)
+		d.diff(x0, x, y0, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.matches = append(d.matches, [2]int{x, y})
+		}
+		d.diff(u, x1, v, y1)
+	}
+	for i := 0; i < suffix; i++ {
+		d.matches = append(d.matches, [2]int{x1 + i, y1 + i})
+	}
+}
+
+// middleSnake returns the start and end of the middle snake of a shortest
+// edit script between a[x0:x1] and b[y0:y1], searching forwards from the
+// start and backwards from the end at once until the paths overlap.
+func (d *differ) middleSnake(x0, x1, y0, y1 int) (x, y, u, v int) {
+	n, m := x1-x0, y1-y0
+	max := (n + m + 1) / 2
+	off := max + 1
+	// vf holds the furthest x reached forwards on each diagonal k = x - y,
+	// and vb the furthest reached backwards, counted from the end, on each
+	// diagonal of the reversed sequences, where k is delta - k.
+	vf := make([]int, 2*max+3)
+	vb := make([]int, 2*max+3)
+	delta := n - m
+	odd := delta%2 != 0
+	for e := 0; e <= max; e++ {
+		for k := -e; k <= e; k += 2 {
+			var xs int
+			if k == -e || (k != e && vf[off+k-1] < vf[off+k+1]) {
+				xs = vf[off+k+1]
+			} else {
+				xs = vf[off+k-1] + 1
+			}
+			xe, ye := xs, xs-k
+			for xe < n && ye < m && d.a[x0+xe] == d.b[y0+ye] {
+				xe++
+				ye++
+			}
+			vf[off+k] = xe
+			if kr := delta - k; odd && kr >= -(e-1) && kr <= e-1 && xe+vb[off+kr] >= n {
+				return x0 + xs, y0 + xs - k, x0 + xe, y0 + ye
+			}
+		}
+		for k := -e; k <= e; k += 2 {
+			var xs int
+			if k == -e || (k != e && vb[off+k-1] < vb[off+k+1]) {
+				xs = vb[off+k+1]
+			} else {
+				xs = vb[off+k-1] + 1
+			}
+			xe, ye := xs, xs-k
+			for xe < n && ye < m && d.a[x1-1-xe] == d.b[y1-1-ye] {
+				xe++
+				ye++
+			}
+			vb[off+k] = xe
+			if kf := delta - k; !odd && kf >= -e && kf <= e && xe+vf[off+kf] >= n {
+				return x1 - xe, y1 - ye, x1 - xs, y1 - xs + k
+			}
+		}
+	}
+	panic("gtfmt: no middle snake")
+}
@@ -0,0 +1,103 @@
+package gtfmt
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	orig := "a\n{{  .B  }}\nc\n{{  .D  }}\n{{  .E  }}\nf"
+	formatted := "a\n{{.B}}\nc\n{{.D}}\n{{.E}}\nf"
+	edits := Diff(orig, formatted)
+	expected := []TextEdit{
+		{Start: 2, End: 13, NewText: "{{.B}}\n"},
+		{Start: 15, End: 37, NewText: "{{.D}}\n{{.E}}\n"},
+	}
+	if !reflect.DeepEqual(expected, edits) {
+		t.Fatalf("expected:\n%#v\n\nbut got:\n%#v", expected, edits)
+	}
+	if out, err := Apply(orig, edits); err != nil || out != formatted {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", formatted, out)
+	}
+}
+
+func TestDiffInsertDelete(t *testing.T) {
+	for _, test := range []struct{ orig, formatted string }{
+		{"", "a\n"},
+		{"a\n", ""},
+		{"a\nb\n", "b\nc\n"},
+		{"x\ny\nz", "x\nz\ny"},
+	} {
+		edits := Diff(test.orig, test.formatted)
+		if out, err := Apply(test.orig, edits); err != nil || out != test.formatted {
+			t.Errorf("%q -> %q: applying %#v gave %q, %v", test.orig, test.formatted, edits, out, err)
+		}
+	}
+	if edits := Diff("same\n", "same\n"); len(edits) != 0 {
+		t.Errorf("expected no edits for identical text, got %#v", edits)
+	}
+}
+
+func TestMatchLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		s := make([]string, r.Intn(12))
+		for i := range s {
+			s[i] = string(rune('a' + r.Intn(3)))
+		}
+		return s
+	}
+	for i := 0; i < 1000; i++ {
+		a, b := lines(), lines()
+		matches := matchLines(a, b)
+		// lcs[i][j] is the length of the longest common subsequence of
+		// a[i:] and b[j:].
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] > lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		if len(matches) != lcs[0][0] {
+			t.Fatalf("%q, %q: expected %d matches but got %v", a, b, lcs[0][0], matches)
+		}
+		last := [2]int{-1, -1}
+		for _, m := range matches {
+			if m[0] <= last[0] || m[1] <= last[1] || a[m[0]] != b[m[1]] {
+				t.Fatalf("%q, %q: invalid matches %v", a, b, matches)
+			}
+			last = m
+		}
+	}
+}
+
+func TestDiffLarge(t *testing.T) {
+	var orig, formatted strings.Builder
+	for i := 0; i < 8000; i++ {
+		fmt.Fprintf(&orig, "line {{  .Field%d  }}\n", i)
+		fmt.Fprintf(&formatted, "line {{.Field%d}}\n", i)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := Diff(orig.String(), formatted.String())
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 64<<20 {
+		t.Errorf("expected at most 64MB allocated but got %dMB", alloc>>20)
+	}
+	if out, err := Apply(orig.String(), edits); err != nil || out != formatted.String() {
+		t.Errorf("applying the edits gave the wrong text: %v", err)
+	}
+}
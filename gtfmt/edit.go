@@ -0,0 +1,56 @@
+package gtfmt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Edits returns the edits that Format would make to tpl, one for each action
+// it changes.
+func Edits(name, tpl string) ([]TextEdit, error) {
+	f := &Formatter{}
+	edits, _, _, err := f.edits(name, tpl)
+	return edits, err
+}
+
+// RangeEdits returns the edits that FormatRange would make to tpl.
+func RangeEdits(name, tpl string, start, end int) ([]TextEdit, error) {
+	return selectedEdits(name, tpl, func(pos int) bool {
+		return pos >= start && pos < end
+	})
+}
+
+// LinesEdits returns the edits that FormatLines would make to tpl.
+func LinesEdits(name, tpl string, lines ...LineRange) ([]TextEdit, error) {
+	return selectedEdits(name, tpl, onLines(tpl, lines))
+}
+
+// FixEdits returns the edits that Fix would make to tpl, along with the
+// number of replacements made.
+func FixEdits(name, tpl, orig, repl string) ([]TextEdit, int, error) {
+	f := &Formatter{opts: Options{Rules: []Rule{{Orig: orig, Repl: repl}}}}
+	edits, _, n, err := f.edits(name, tpl)
+	return edits, n, err
+}
+
+// Apply returns tpl with edits made to it. The edits may be in any order, but
+// must not overlap.
+func Apply(tpl string, edits []TextEdit) (string, error) {
+	sorted := append([]TextEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	var buf strings.Builder
+	last := 0
+	for _, e := range sorted {
+		if e.Start < last || e.End < e.Start || e.End > len(tpl) {
+			return "", fmt.Errorf("invalid edit of bytes %d-%d of %d", e.Start, e.End, len(tpl))
+		}
+		buf.WriteString(tpl[last:e.Start])
+		buf.WriteString(e.NewText)
+		last = e.End
+	}
+	buf.WriteString(tpl[last:])
+	return buf.String(), nil
+}
@@ -0,0 +1,78 @@
+package gtfmt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEdits(t *testing.T) {
+	tpl := "a {{- /* c */ -}} b\n{{  if  .A  }}x{{  else if .B }}y{{end}}\n"
+	edits, err := Edits("tpl", tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{
+		{Start: 1, End: 18, NewText: ""},
+		{Start: 20, End: 34, NewText: "{{if .A}}"},
+		{Start: 35, End: 52, NewText: "{{else}}{{if .B}}"},
+		{Start: 53, End: 60, NewText: "{{end}}{{end}}"},
+	}
+	if !reflect.DeepEqual(expected, edits) {
+		t.Fatalf("expected:\n%#v\n\nbut got:\n%#v", expected, edits)
+	}
+	out, err := Apply(tpl, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := Format("tpl", tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != formatted {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", formatted, out)
+	}
+	if edits, err := Edits("tpl", formatted); err != nil || len(edits) != 0 {
+		t.Errorf("expected no edits for formatted template, got %#v, %v", edits, err)
+	}
+}
+
+func TestFixEdits(t *testing.T) {
+	tpl := "{{  .A  }} {{.B}} {{ .A.C }}"
+	edits, n, err := FixEdits("tpl", tpl, ".A", ".X")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{
+		{Start: 0, End: 10, NewText: "{{.X}}"},
+		{Start: 18, End: 28, NewText: "{{.X.C}}"},
+	}
+	if !reflect.DeepEqual(expected, edits) {
+		t.Fatalf("expected:\n%#v\n\nbut got:\n%#v", expected, edits)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 replacements, got %d", n)
+	}
+}
+
+func TestApply(t *testing.T) {
+	out, err := Apply("abcdef", []TextEdit{
+		{Start: 4, End: 5, NewText: "E"},
+		{Start: 0, End: 0, NewText: ">"},
+		{Start: 1, End: 3, NewText: ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := ">adEf"; out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+	for _, edits := range [][]TextEdit{
+		{{Start: 0, End: 3}, {Start: 2, End: 4}},
+		{{Start: 3, End: 2}},
+		{{Start: 5, End: 7}},
+	} {
+		if _, err := Apply("abcdef", edits); err == nil {
+			t.Errorf("expected error applying %#v", edits)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package gtfmt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// Error is an error in a template at a known position, such as a syntax error.
+type Error struct {
+	Filename string // the name of the template
+	Line     int    // 1-based line number
+	Column   int    // 1-based column, counting bytes
+	Offset   int    // byte offset in the template
+	Msg      string
+
+	// Snippet is the line containing the error, followed by a line with a
+	// caret under the error.
+	Snippet string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Line, e.Column, e.Msg)
+}
+
+// ErrorList is the syntax errors in a template, in the order they appear, as
+// reported by a Formatter with the AllErrors option.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// newError returns err, with a parse error in text turned into an *Error.
+func newError(name, text string, err error) error {
+	if perr, ok := err.(*parse.Error); ok {
+		return errorAt(name, text, int(perr.Pos), perr.Msg)
+	}
+	return err
+}
+
+// errorAt returns an *Error for msg at the byte offset off in text.
+func errorAt(name, text string, off int, msg string) *Error {
+	if off > len(text) {
+		off = len(text)
+	}
+	start := strings.LastIndexByte(text[:off], '\n') + 1
+	end := strings.IndexByte(text[off:], '\n')
+	if end < 0 {
+		end = len(text)
+	} else {
+		end += off
+	}
+	line := strings.TrimSuffix(text[start:end], "\r")
+	// Keep the tabs before the error so the caret lines up with it.
+	caret := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, text[start:off])
+	return &Error{
+		Filename: name,
+		Line:     1 + strings.Count(text[:off], "\n"),
+		Column:   off - start + 1,
+		Offset:   off,
+		Msg:      msg,
+		Snippet:  line + "\n" + caret + "^",
+	}
+}
@@ -0,0 +1,24 @@
+package gtfmt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	_, err := Format("page.tmpl", "a\n\t{{.X ) }}\r\nb")
+	expected := &Error{
+		Filename: "page.tmpl",
+		Line:     2,
+		Column:   7,
+		Offset:   8,
+		Msg:      `unexpected ")" in input`,
+		Snippet:  "\t{{.X ) }}\n\t     ^",
+	}
+	if !reflect.DeepEqual(expected, err) {
+		t.Fatalf("expected:\n%#v\n\nbut got:\n%#v", expected, err)
+	}
+	if s, expected := err.Error(), `page.tmpl:2:7: unexpected ")" in input`; s != expected {
+		t.Errorf("expected %q but got %q", expected, s)
+	}
+}
@@ -9,40 +9,41 @@ import (
 
 // Formatted reports whether the text in the given template is correctly formatted.
 func Formatted(name, tpl string) (bool, error) {
-	tree, err := parse.ParseNoFuncs(name, tpl, "", "")
+	edits, err := Edits(name, tpl)
 	if err != nil {
 		return false, err
 	}
-	if len(tree) > 1 {
-		return false, fmt.Errorf("%v: sub templates not currently supported", name)
-	}
-	return tpl == tree[name].Root.String(), nil
+	return len(edits) == 0, nil
 }
 
 // Format formats the code inside your template statements without changing any
 // other surrounding text.
 func Format(name, tpl string) (string, error) {
-	tree, err := parse.ParseNoFuncs(name, tpl, "", "")
-	if err != nil {
-		return "", err
-	}
-	if len(tree) > 1 {
-		return "", fmt.Errorf("%v: sub templates not currently supported", name)
-	}
-	return tree[name].Root.String(), nil
+	f := &Formatter{}
+	return f.format(name, tpl)
 }
 
 // Fix replaces orig with repl in tpl. tpl must be a valid go template.  Orig
 // must be a valid template function name or . path (e.g. .Foo.Bar).  Paths
 // *must* start with a ".".
 func Fix(name, tpl, orig, repl string) (string, error) {
-	tree, err := parse.ParseNoFuncs(name, tpl, "", "")
+	s, _, err := FixCount(name, tpl, orig, repl)
+	return s, err
+}
+
+// FixCount is like Fix, but also returns the number of replacements made.
+func FixCount(name, tpl, orig, repl string) (string, int, error) {
+	edits, n, err := FixEdits(name, tpl, orig, repl)
 	if err != nil {
-		return "", err
-	}
-	if len(tree) > 1 {
-		return "", fmt.Errorf("%v: sub templates not currently supported", name)
+		return "", 0, err
 	}
+	s, err := Apply(tpl, edits)
+	return s, n, err
+}
+
+// newRewriter returns a state that replaces orig with repl as it walks a
+// tree.
+func newRewriter(orig, repl string) *state {
 	s := &state{}
 	if strings.HasPrefix(orig, ".") {
 		// append a dot at the end to ensure we get full word matching
@@ -60,14 +61,18 @@ func Fix(name, tpl, orig, repl string) (string, error) {
 		s.fn = orig
 		s.repl = repl
 	}
-	s.walk(tree[name].Root)
-	return tree[name].Root.String(), nil
+	return s
 }
 
 type state struct {
-	fn   string
-	path string
-	repl string
+	fn      string
+	path    string
+	repl    string
+	matches int // number of replacements made
+
+	record bool // if true, record function calls in calls
+	calls  []FuncCall
+	stage  int // index of the command being walked in its pipeline
 }
 
 // walk steps through the major pieces of the template structure.
@@ -76,12 +81,16 @@ func (s *state) walk(node parse.Node) {
 		return
 	}
 	switch node := node.(type) {
-	case *parse.StringNode, *parse.TextNode, *parse.VariableNode, *parse.BoolNode, *parse.NumberNode:
+	case *parse.StringNode, *parse.TextNode, *parse.VariableNode, *parse.BoolNode, *parse.NumberNode,
+		*parse.DotNode, *parse.NilNode:
 		// nothing to do
+	case *parse.ChainNode:
+		s.walk(node.Node)
 	case *parse.ActionNode:
 		s.walk(node.Pipe)
 	case *parse.PipeNode:
-		for _, n := range node.Cmds {
+		for i, n := range node.Cmds {
+			s.stage = i
 			s.walk(n)
 		}
 		for _, n := range node.Decl {
@@ -94,6 +103,9 @@ func (s *state) walk(node parse.Node) {
 	case *parse.WithNode:
 		s.walkBranch(node.BranchNode)
 	case *parse.ListNode:
+		if node == nil {
+			return
+		}
 		for _, n := range node.Nodes {
 			s.walk(n)
 		}
@@ -102,8 +114,12 @@ func (s *state) walk(node parse.Node) {
 	case *parse.IdentifierNode:
 		if s.fn != "" && node.Ident == s.fn {
 			node.Ident = s.repl
+			s.matches++
 		}
 	case *parse.CommandNode:
+		if s.record {
+			s.recordCalls(node)
+		}
 		for _, n := range node.Args {
 			s.walk(n)
 		}
@@ -118,6 +134,7 @@ func (s *state) walk(node parse.Node) {
 		}
 		val := strings.Trim(strings.Replace(ident, s.path, s.repl, 1), ".")
 		node.Ident = strings.Split(val, ".")
+		s.matches++
 	default:
 		panic(fmt.Sprintf("unknown node: %T", node))
 	}
@@ -68,3 +68,30 @@ func TestNoParseSubTemplate(t *testing.T) {
 		t.Fatalf("wrong error message from subtemplate: %v", err)
 	}
 }
+
+func TestFixCount(t *testing.T) {
+	tpl := `{{if .Foo.Bar}}{{.Foo.Bar.Baz}}{{end}}{{.Foo}}`
+	out, n, err := FixCount("tpl", tpl, ".Foo.Bar", ".Qux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{{if .Qux}}{{.Qux.Baz}}{{end}}{{.Foo}}`
+	if out != expected {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, out)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 matches, got %d", n)
+	}
+}
+
+func TestFixAllNodes(t *testing.T) {
+	tpl := `{{if eq . nil}}{{(foo .X).Y}}{{end}}`
+	out, err := Fix("tpl", tpl, "foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{{if eq . nil}}{{(bar .X).Y}}{{end}}`
+	if out != expected {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, out)
+	}
+}
@@ -0,0 +1,284 @@
+package gtfmt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// Rule is a rewrite rule, replacing Orig with Repl as Fix does.
+type Rule struct {
+	Orig string // a function name, or a path starting with "."
+	Repl string
+}
+
+// Options configure a Formatter. The zero value formats as Format does.
+type Options struct {
+	LeftDelim  string // the left action delimiter; "" for {{
+	RightDelim string // the right action delimiter; "" for }}
+
+	// KeepSpace reformats each action in place, as FormatActions does,
+	// rather than reprinting the whole template: the whitespace that trim
+	// markers remove and comments are kept, and templates declared with
+	// define are formatted too. It is implied by custom delimiters, and may
+	// not be used with Simplify.
+	KeepSpace bool
+
+	Simplify Simplification // simplifications to make
+	Rules    []Rule         // rewrite rules applied, in order, before formatting
+
+	// Verify parses the formatted template and checks that it is the same
+	// template as the original, after any rewrites and simplifications,
+	// returning an error rather than output that would behave differently.
+	Verify bool
+
+	// AllErrors reports every syntax error in a template that doesn't
+	// parse, as an ErrorList, rather than only the first.
+	AllErrors bool
+
+	// Partial formats each action from its tokens alone, without parsing
+	// the template, so that templates that don't parse, such as those
+	// being edited with blocks left open, are still formatted. Actions that
+	// don't lex are left as they are. It implies KeepSpace, and may not be
+	// used with Simplify, Rules or Verify.
+	Partial bool
+}
+
+// Source is a template to format, along with the name of the file it came
+// from, which names the template in errors.
+type Source struct {
+	Filename string
+	Text     []byte
+}
+
+// defaultName names templates read without a filename.
+const defaultName = "template"
+
+// Formatter formats templates according to its Options.
+type Formatter struct {
+	opts Options
+}
+
+// NewFormatter returns a Formatter with the given options.
+func NewFormatter(opts Options) (*Formatter, error) {
+	if opts.Partial && (opts.Simplify != 0 || len(opts.Rules) > 0 || opts.Verify) {
+		return nil, errors.New("partial formatting works from tokens alone, so may not be used with simplifications, rewrite rules or verification")
+	}
+	if opts.LeftDelim != "" || opts.RightDelim != "" || opts.Partial {
+		opts.KeepSpace = true
+	}
+	if opts.KeepSpace && opts.Simplify != 0 {
+		return nil, errors.New("simplifications require reprinting the whole template, so may not be used with KeepSpace or custom delimiters")
+	}
+	for _, r := range opts.Rules {
+		if r.Orig == "" {
+			return nil, errors.New("rewrite rule with nothing to replace")
+		}
+	}
+	return &Formatter{opts: opts}, nil
+}
+
+// Format reads a template from r and writes it formatted to w. If r has a
+// Name method, as an *os.File does, the name names the template in errors.
+func (f *Formatter) Format(r io.Reader, w io.Writer) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	src := Source{Text: b}
+	if n, ok := r.(interface{ Name() string }); ok {
+		src.Filename = n.Name()
+	}
+	out, err := f.FormatSource(src)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// FormatBytes formats the template in src.
+func (f *Formatter) FormatBytes(src []byte) ([]byte, error) {
+	return f.FormatSource(Source{Text: src})
+}
+
+// FormatSource formats the template in src.
+func (f *Formatter) FormatSource(src Source) ([]byte, error) {
+	b, _, err := f.FormatCount(src)
+	return b, err
+}
+
+// FormatCount is like FormatSource, but also returns the number of
+// replacements made by the rewrite rules.
+func (f *Formatter) FormatCount(src Source) ([]byte, int, error) {
+	name := src.Filename
+	if name == "" {
+		name = defaultName
+	}
+	s, n, err := f.formatCount(name, string(src.Text))
+	if err != nil {
+		return nil, 0, err
+	}
+	return []byte(s), n, nil
+}
+
+// Edits returns the edits that formatting src makes to it, one for each
+// action changed.
+func (f *Formatter) Edits(src Source) ([]TextEdit, error) {
+	edits, _, err := f.EditsCount(src)
+	return edits, err
+}
+
+// EditsCount is like Edits, but also returns the number of replacements made
+// by the rewrite rules.
+func (f *Formatter) EditsCount(src Source) ([]TextEdit, int, error) {
+	name := src.Filename
+	if name == "" {
+		name = defaultName
+	}
+	edits, trees, n, err := f.edits(name, string(src.Text))
+	if err != nil || !f.opts.Verify {
+		return edits, n, err
+	}
+	out, err := Apply(string(src.Text), edits)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := f.verify(name, out, trees); err != nil {
+		return nil, 0, err
+	}
+	return edits, n, nil
+}
+
+func (f *Formatter) format(name, tpl string) (string, error) {
+	s, _, err := f.formatCount(name, tpl)
+	return s, err
+}
+
+func (f *Formatter) formatCount(name, tpl string) (string, int, error) {
+	edits, trees, matches, err := f.edits(name, tpl)
+	if err != nil {
+		return "", 0, err
+	}
+	out, err := Apply(tpl, edits)
+	if err != nil {
+		return "", 0, err
+	}
+	if f.opts.Verify {
+		if err := f.verify(name, out, trees); err != nil {
+			return "", 0, err
+		}
+	}
+	return out, matches, nil
+}
+
+// edits returns the edits that format tpl, the trees the formatted template
+// should parse to and the number of replacements made by the rewrite rules.
+func (f *Formatter) edits(name, tpl string) ([]TextEdit, map[string]*parse.Tree, int, error) {
+	if f.opts.Partial {
+		return partialEdits(tpl, f.opts.LeftDelim, f.opts.RightDelim), nil, 0, nil
+	}
+	trees, err := parse.ParseNoFuncs(name, tpl, f.opts.LeftDelim, f.opts.RightDelim)
+	if err != nil {
+		if f.opts.AllErrors {
+			_, errs := parse.ParseRecover(name, tpl, f.opts.LeftDelim, f.opts.RightDelim)
+			if len(errs) > 0 {
+				list := make(ErrorList, len(errs))
+				for i, e := range errs {
+					list[i] = errorAt(name, tpl, int(e.Pos), e.Msg)
+				}
+				return nil, nil, 0, list
+			}
+		}
+		return nil, nil, 0, newError(name, tpl, err)
+	}
+	if len(trees) > 1 && !f.opts.KeepSpace {
+		return nil, nil, 0, fmt.Errorf("%v: sub templates not currently supported", name)
+	}
+	matches := 0
+	for _, r := range f.opts.Rules {
+		s := newRewriter(r.Orig, r.Repl)
+		for _, tree := range trees {
+			s.walk(tree.Root)
+		}
+		matches += s.matches
+	}
+	if !f.opts.KeepSpace && f.opts.Simplify != 0 {
+		simplifier(f.opts.Simplify).list(trees[name].Root)
+	}
+	all := func(int) bool { return true }
+	edits, err := actionEdits(name, tpl, f.opts.LeftDelim, f.opts.RightDelim, trees, all, !f.opts.KeepSpace)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return edits, trees, matches, nil
+}
+
+// verify checks that out parses to the same templates as trees.
+func (f *Formatter) verify(name, out string, trees map[string]*parse.Tree) error {
+	got, err := parse.ParseNoFuncs(name, out, f.opts.LeftDelim, f.opts.RightDelim)
+	if err != nil {
+		return fmt.Errorf("%s: formatted template doesn't parse: %v", name, err)
+	}
+	if canonical(got) != canonical(trees) {
+		return fmt.Errorf("%s: formatted template differs from the original", name)
+	}
+	return nil
+}
+
+// canonical returns the nodes of each of the templates in trees, in a form
+// that is the same for templates that behave the same. Unlike the text of
+// the templates, it tells apart e.g. an identifier containing a space from
+// two identifiers.
+func canonical(trees map[string]*parse.Tree) string {
+	var names []string
+	for name := range trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%q\n", name)
+		writeNodes(&buf, trees[name].Root)
+	}
+	return buf.String()
+}
+
+// writeNodes writes the nodes of the tree at node to buf, one per line, with
+// adjacent text, such as text either side of a comment, written as one.
+func writeNodes(buf *strings.Builder, node parse.Node) {
+	parse.Inspect(node, func(n parse.Node) bool {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			buf.WriteString("list\n")
+			var text []byte
+			for _, c := range n.Nodes {
+				if t, ok := c.(*parse.TextNode); ok {
+					text = append(text, t.Text...)
+					continue
+				}
+				if len(text) > 0 {
+					fmt.Fprintf(buf, "text %q\n", text)
+					text = nil
+				}
+				writeNodes(buf, c)
+			}
+			if len(text) > 0 {
+				fmt.Fprintf(buf, "text %q\n", text)
+			}
+			buf.WriteString("end\n")
+			return false
+		case *parse.ActionNode, *parse.PipeNode, *parse.CommandNode,
+			*parse.IfNode, *parse.RangeNode, *parse.WithNode:
+			fmt.Fprintf(buf, "%T\n", n)
+		default:
+			fmt.Fprintf(buf, "%T %s\n", n, n)
+		}
+		return true
+	})
+}
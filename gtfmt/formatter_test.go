@@ -0,0 +1,137 @@
+package gtfmt
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// namedReader is a reader with a name, as an *os.File is.
+type namedReader struct {
+	*strings.Reader
+	name string
+}
+
+func (r namedReader) Name() string { return r.name }
+
+func TestFormatterFormat(t *testing.T) {
+	f, err := NewFormatter(Options{Simplify: SimplifyParens, Rules: []Rule{{Orig: "old", Repl: "new"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.Format(strings.NewReader(`{{  old  (.X)  }}`), &buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{{new .X}}`
+	if s := buf.String(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+
+	err = f.Format(namedReader{strings.NewReader(`{{.X)}}`), "page.tmpl"}, &buf)
+	if err == nil || !strings.HasPrefix(err.Error(), "page.tmpl:1:") {
+		t.Errorf("expected an error naming page.tmpl but got %v", err)
+	}
+	_, err = f.FormatBytes([]byte(`{{.X)}}`))
+	if err == nil || !strings.HasPrefix(err.Error(), "template:1:") {
+		t.Errorf("expected an error naming the template but got %v", err)
+	}
+}
+
+func TestFormatterKeepSpace(t *testing.T) {
+	f, err := NewFormatter(Options{
+		LeftDelim:  "[[",
+		RightDelim: "]]",
+		Rules:      []Rule{{Orig: ".Name", Repl: ".FullName"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := Source{Filename: "page.tmpl", Text: []byte("[[- define  \"x\" ]]\n  [[  .Name  ]] {{  .Name  }}\n[[- end ]]")}
+	out, err := f.FormatSource(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[[- define \"x\"]]\n  [[.FullName]] {{  .Name  }}\n[[- end]]"
+	if string(out) != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, out)
+	}
+
+	if _, err := NewFormatter(Options{LeftDelim: "[[", RightDelim: "]]", Simplify: SimplifyAll}); err == nil {
+		t.Error("expected an error simplifying with custom delimiters")
+	}
+	if _, err := NewFormatter(Options{Rules: []Rule{{Repl: "x"}}}); err == nil {
+		t.Error("expected an error for an empty rewrite rule")
+	}
+}
+
+func TestFormatterVerify(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		err  string
+	}{
+		{Rule{Orig: "f", Repl: "g"}, ""},
+		{Rule{Orig: "f", Repl: "1x"}, "x: formatted template doesn't parse: "},
+		{Rule{Orig: "f", Repl: "g h"}, "x: formatted template differs from the original"},
+	}
+	for _, test := range tests {
+		f, err := NewFormatter(Options{Rules: []Rule{test.rule}, Verify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.FormatSource(Source{Filename: "x", Text: []byte(`{{f .X}}`)})
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", test.rule, err)
+		case test.err != "" && (err == nil || !strings.HasPrefix(err.Error(), test.err)):
+			t.Errorf("%v: expected error %q but got %v", test.rule, test.err, err)
+		}
+	}
+}
+
+func TestFormatterVerifyComments(t *testing.T) {
+	f, err := NewFormatter(Options{Verify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.FormatBytes([]byte("a{{/* c */}}b{{if .X}}c{{end}}d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "ab{{if .X}}c{{end}}d"
+	if string(out) != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, out)
+	}
+}
+
+func TestFormatterEdits(t *testing.T) {
+	f, err := NewFormatter(Options{KeepSpace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits, err := f.Edits(Source{Filename: "a.tmpl", Text: []byte("a {{-  .X }} b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{{Start: 2, End: 12, NewText: "{{- .X}}"}}
+	if !reflect.DeepEqual(expected, edits) {
+		t.Errorf("expected:\n%#v\n\nbut got:\n%#v", expected, edits)
+	}
+}
+
+func TestFormatterAllErrors(t *testing.T) {
+	f, err := NewFormatter(Options{AllErrors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.FormatSource(Source{Filename: "a.tmpl", Text: []byte("{{.A ) }}\n{{if}}\n")})
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected an ErrorList but got %#v", err)
+	}
+	expected := "a.tmpl:1:6: unexpected \")\" in input\na.tmpl:2:5: missing value for if"
+	if s := errs.Error(); s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+}
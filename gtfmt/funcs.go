@@ -0,0 +1,83 @@
+package gtfmt
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// FuncCall is a call of a template function.
+type FuncCall struct {
+	Name  string // the function name, e.g. printf or strings.Index
+	Pos   int    // byte offset of the name in the template
+	Args  int    // number of arguments written after the name
+	Stage int    // index of the command in its pipeline
+	Piped bool   // if true, the value of the previous command is passed as a final argument
+	Arg   bool   // if true, called with no arguments as an argument of another command
+}
+
+// Arity returns the number of arguments the function is called with,
+// including a piped value.
+func (c FuncCall) Arity() int {
+	if c.Piped {
+		return c.Args + 1
+	}
+	return c.Args
+}
+
+// FuncCalls returns every call of a function in tpl, including in sub
+// templates, in the order they appear.
+func FuncCalls(name, tpl string) ([]FuncCall, error) {
+	return FuncCallsDelims(name, tpl, "", "")
+}
+
+// FuncCallsDelims is like FuncCalls, but for templates whose actions are
+// delimited by leftDelim and rightDelim rather than {{ and }}. Empty
+// delimiters are the defaults.
+func FuncCallsDelims(name, tpl, leftDelim, rightDelim string) ([]FuncCall, error) {
+	trees, err := parse.ParseNoFuncs(name, tpl, leftDelim, rightDelim)
+	if err != nil {
+		return nil, newError(name, tpl, err)
+	}
+	s := &state{record: true}
+	for _, tree := range trees {
+		s.walk(tree.Root)
+	}
+	sort.SliceStable(s.calls, func(i, j int) bool { return s.calls[i].Pos < s.calls[j].Pos })
+	return s.calls, nil
+}
+
+// recordCalls records the functions called by cmd, either as its first word
+// or as arguments.
+func (s *state) recordCalls(cmd *parse.CommandNode) {
+	for i, arg := range cmd.Args {
+		name, pos, ok := funcName(arg)
+		if !ok {
+			continue
+		}
+		c := FuncCall{Name: name, Pos: pos}
+		if i == 0 {
+			c.Args = len(cmd.Args) - 1
+			c.Stage = s.stage
+			c.Piped = s.stage > 0
+		} else {
+			c.Arg = true
+		}
+		s.calls = append(s.calls, c)
+	}
+}
+
+// funcName returns the name of the function node calls, if it is a function
+// or a namespaced function such as strings.Index.
+func funcName(node parse.Node) (name string, pos int, ok bool) {
+	switch node := node.(type) {
+	case *parse.IdentifierNode:
+		return node.Ident, int(node.Pos), true
+	case *parse.ChainNode:
+		if id, ok := node.Node.(*parse.IdentifierNode); ok {
+			return id.Ident + "." + strings.Join(node.Field, "."), int(id.Pos), true
+		}
+	}
+	return "", 0, false
+}
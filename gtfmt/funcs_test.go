@@ -0,0 +1,34 @@
+package gtfmt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuncCalls(t *testing.T) {
+	tpl := `{{printf "%s" .X | upper | printf "%s-%s" "a"}}
+{{if eq (len .L) 0}}{{strings.Index .S "x"}}{{end}}{{define "x"}}{{join . (list)}}{{end}}
+{{html now}}`
+	calls, err := FuncCalls("page", tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FuncCall{
+		{Name: "printf", Pos: 2, Args: 2},
+		{Name: "upper", Pos: 19, Stage: 1, Piped: true},
+		{Name: "printf", Pos: 27, Args: 2, Stage: 2, Piped: true},
+		{Name: "eq", Pos: 53, Args: 2},
+		{Name: "len", Pos: 57, Args: 1},
+		{Name: "strings.Index", Pos: 70, Args: 2},
+		{Name: "join", Pos: 115, Args: 2},
+		{Name: "list", Pos: 123},
+		{Name: "html", Pos: 140, Args: 1},
+		{Name: "now", Pos: 145, Arg: true},
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected:\n%+v\n\nbut got:\n%+v", expected, calls)
+	}
+	if a := calls[2].Arity(); a != 3 {
+		t.Errorf("expected arity 3 but got %d", a)
+	}
+}
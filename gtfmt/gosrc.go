@@ -0,0 +1,140 @@
+package gtfmt
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormatGo formats the templates in the string literals of the Go source src
+// that are parsed as text/template or html/template templates: literals
+// passed to a Parse method, directly or as a constant or variable declared in
+// the same file. The kind of each literal, raw or interpreted, and all other
+// code are left as they are. Literals that aren't valid templates, or that
+// define sub templates, are skipped.
+func FormatGo(name, src string) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	pkgs := importNames(f)
+	if !pkgs["template"] {
+		return src, nil
+	}
+	seen := map[token.Pos]bool{}
+	var lits []*ast.BasicLit
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Parse" {
+			return true
+		}
+		// Parse functions of other packages, such as url.Parse.
+		if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil && pkgs[id.Name] {
+			return true
+		}
+		if lit := stringLit(call.Args[0]); lit != nil && !seen[lit.Pos()] {
+			seen[lit.Pos()] = true
+			lits = append(lits, lit)
+		}
+		return true
+	})
+	sort.Slice(lits, func(i, j int) bool { return lits[i].Pos() < lits[j].Pos() })
+
+	file := fset.File(f.Pos())
+	var buf strings.Builder
+	last := 0
+	for _, lit := range lits {
+		s, ok := formatLit(name, lit.Value)
+		if !ok {
+			continue
+		}
+		start := file.Offset(lit.Pos())
+		buf.WriteString(src[last:start])
+		buf.WriteString(s)
+		last = start + len(lit.Value)
+	}
+	buf.WriteString(src[last:])
+	return buf.String(), nil
+}
+
+// importNames returns the names the file's imports are known by, with
+// text/template and html/template also known as template.
+func importNames(f *ast.File) map[string]bool {
+	names := map[string]bool{}
+	for _, imp := range f.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		names[name] = true
+		if path == "text/template" || path == "html/template" {
+			names["template"] = true
+		}
+	}
+	return names
+}
+
+// stringLit returns the string literal expr is, or that the constant or
+// variable expr names is declared with.
+func stringLit(expr ast.Expr) *ast.BasicLit {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		if expr.Kind == token.STRING {
+			return expr
+		}
+	case *ast.Ident:
+		if expr.Obj == nil {
+			return nil
+		}
+		spec, ok := expr.Obj.Decl.(*ast.ValueSpec)
+		if !ok {
+			return nil
+		}
+		for i, n := range spec.Names {
+			if n.Name == expr.Name && i < len(spec.Values) {
+				if lit, ok := spec.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					return lit
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// formatLit formats the template in the Go string literal lit, returning the
+// new literal of the same kind, and whether it could be formatted.
+func formatLit(name, lit string) (string, bool) {
+	text, err := strconv.Unquote(lit)
+	if err != nil {
+		return "", false
+	}
+	// Requoting would rewrite escapes such as \u00e9 that Quote writes
+	// differently, so such literals are left as they are.
+	if lit[0] == '"' && strconv.Quote(text) != lit {
+		return "", false
+	}
+	s, err := Format(name, text)
+	if err != nil || s == text {
+		return "", false
+	}
+	if lit[0] == '`' {
+		// Raw strings can't hold a backquote, and drop carriage returns.
+		if strings.ContainsAny(s, "`\r") {
+			return "", false
+		}
+		return "`" + s + "`", true
+	}
+	return strconv.Quote(s), true
+}
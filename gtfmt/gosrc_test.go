@@ -0,0 +1,84 @@
+package gtfmt
+
+import (
+	"testing"
+)
+
+func TestFormatGo(t *testing.T) {
+	src := "package web\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"html/template\"\n" +
+		"\t\"net/url\"\n" +
+		")\n" +
+		"\n" +
+		"const page = `<h1>{{  .Title  }}</h1>\n" +
+		"{{range  .Items}}{{  . }}{{end}}`\n" +
+		"\n" +
+		"var (\n" +
+		"\tt1 = template.Must(template.New(\"page\").Parse(page))\n" +
+		"\tt2 = template.Must(template.New(\"item\").Parse(\"<li>{{  .Name  }}</li>\\n\"))\n" +
+		"\tt3 = template.Must(template.New(\"bad\").Parse(`{{  .X `))\n" +
+		"\tt4 = template.Must(template.New(\"defs\").Parse(`{{define \"a\"}}{{  .A  }}{{end}}`))\n" +
+		"\tu, _ = url.Parse(\"{{  .X  }}\")\n" +
+		"\ts = fmt(\"{{  .X  }}\")\n" +
+		")\n"
+	expected := "package web\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"html/template\"\n" +
+		"\t\"net/url\"\n" +
+		")\n" +
+		"\n" +
+		"const page = `<h1>{{.Title}}</h1>\n" +
+		"{{range .Items}}{{.}}{{end}}`\n" +
+		"\n" +
+		"var (\n" +
+		"\tt1 = template.Must(template.New(\"page\").Parse(page))\n" +
+		"\tt2 = template.Must(template.New(\"item\").Parse(\"<li>{{.Name}}</li>\\n\"))\n" +
+		"\tt3 = template.Must(template.New(\"bad\").Parse(`{{  .X `))\n" +
+		"\tt4 = template.Must(template.New(\"defs\").Parse(`{{define \"a\"}}{{  .A  }}{{end}}`))\n" +
+		"\tu, _ = url.Parse(\"{{  .X  }}\")\n" +
+		"\ts = fmt(\"{{  .X  }}\")\n" +
+		")\n"
+	s, err := FormatGo("web.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+}
+
+func TestFormatGoNoTemplateImport(t *testing.T) {
+	src := "package web\n\nvar t = p.Parse(`{{  .X  }}`)\n"
+	s, err := FormatGo("web.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != src {
+		t.Errorf("expected:\n%s\nbut got:\n%s", src, s)
+	}
+}
+
+func TestFormatGoEscapes(t *testing.T) {
+	src := "package web\n\nimport \"text/template\"\n\n" +
+		"var t1 = template.Must(template.New(\"a\").Parse(\"{{  .X  }}\\u00e9\\x41\"))\n" +
+		"var t2 = template.Must(template.New(\"b\").Parse(\"{{  .Y  }}é\\n\"))\n"
+	expected := "package web\n\nimport \"text/template\"\n\n" +
+		"var t1 = template.Must(template.New(\"a\").Parse(\"{{  .X  }}\\u00e9\\x41\"))\n" +
+		"var t2 = template.Must(template.New(\"b\").Parse(\"{{.Y}}é\\n\"))\n"
+	s, err := FormatGo("web.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+}
+
+func TestFormatGoError(t *testing.T) {
+	if _, err := FormatGo("web.go", "package web\n\nvar ("); err == nil {
+		t.Error("expected an error for invalid Go source")
+	}
+}
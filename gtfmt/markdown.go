@@ -0,0 +1,184 @@
+package gtfmt
+
+import (
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// markdownLangs are the info strings that mark a fenced code block in
+// Markdown as a Go template.
+var markdownLangs = map[string]bool{
+	"gotemplate":  true,
+	"go-template": true,
+	"tmpl":        true,
+}
+
+// BlockErrors is returned by FormatMarkdown for the code blocks that could not
+// be parsed. Each is an *Error whose position is that in the Markdown file.
+type BlockErrors []error
+
+func (e BlockErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// FormatMarkdown formats the fenced code blocks in the Markdown text md that
+// are tagged as Go templates (```gotemplate, ```go-template or ```tmpl),
+// leaving the rest of the text as it is. Blocks that define sub templates are
+// skipped. If any blocks can't be parsed, the text is returned with the other
+// blocks formatted, along with a BlockErrors.
+func FormatMarkdown(name, md string) (string, error) {
+	var buf strings.Builder
+	var errs BlockErrors
+	last := 0
+	for _, b := range fencedBlocks(md) {
+		tpl, ok := unindent(md[b.start:b.end], b.indent)
+		if !ok {
+			continue
+		}
+		trees, err := parse.ParseNoFuncs(name, tpl, "", "")
+		if err != nil {
+			if perr, ok := err.(*parse.Error); ok {
+				err = errorAt(name, md, b.offset(md, tpl, int(perr.Pos)), perr.Msg)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if len(trees) > 1 {
+			continue
+		}
+		s := trees[name].Root.String()
+		if s == tpl {
+			continue
+		}
+		buf.WriteString(md[last:b.start])
+		buf.WriteString(indent(s, b.indent))
+		last = b.end
+	}
+	buf.WriteString(md[last:])
+	if errs != nil {
+		return buf.String(), errs
+	}
+	return buf.String(), nil
+}
+
+// fencedBlock is the content of a fenced code block in Markdown.
+type fencedBlock struct {
+	start, end int // byte offsets of the content
+	indent     int // indentation of the opening fence
+}
+
+// offset returns the offset in md of the byte at off in tpl, the content of
+// the block with its indentation removed.
+func (b fencedBlock) offset(md, tpl string, off int) int {
+	lineStart := strings.LastIndex(tpl[:off], "\n") + 1
+	pos := b.start
+	for n := strings.Count(tpl[:off], "\n"); n > 0; n-- {
+		pos += strings.IndexByte(md[pos:], '\n') + 1
+	}
+	spaces := len(md[pos:]) - len(strings.TrimLeft(md[pos:], " "))
+	if spaces > b.indent {
+		spaces = b.indent
+	}
+	return pos + spaces + off - lineStart
+}
+
+// fencedBlocks returns the fenced code blocks in md tagged as Go templates.
+// Blocks in block quotes are not recognised.
+func fencedBlocks(md string) []fencedBlock {
+	var blocks []fencedBlock
+	var open *fencedBlock // the template block being read, if any
+	var fence string      // the opening fence of the block being read
+	for i := 0; i < len(md); {
+		next := len(md)
+		if nl := strings.IndexByte(md[i:], '\n'); nl >= 0 {
+			next = i + nl + 1
+		}
+		text := strings.TrimRight(md[i:next], "\r\n")
+		trimmed := strings.TrimLeft(text, " ")
+		n := len(text) - len(trimmed)
+		switch {
+		case fence == "":
+			if n > 3 {
+				break
+			}
+			f := fencePrefix(trimmed)
+			if f == "" {
+				break
+			}
+			info := strings.TrimSpace(trimmed[len(f):])
+			if f[0] == '`' && strings.Contains(info, "`") {
+				break
+			}
+			fence = f
+			if lang := strings.Fields(info); len(lang) > 0 && markdownLangs[strings.ToLower(lang[0])] {
+				open = &fencedBlock{start: next, indent: n}
+			}
+		case n <= 3 && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" \t") == "":
+			if open != nil {
+				open.end = i
+				blocks = append(blocks, *open)
+			}
+			open, fence = nil, ""
+		}
+		i = next
+	}
+	if open != nil {
+		// An unclosed block runs to the end of the document.
+		open.end = len(md)
+		blocks = append(blocks, *open)
+	}
+	return blocks
+}
+
+// fencePrefix returns the run of three or more backticks or tildes that text
+// starts with, or "" if it doesn't start with a code fence.
+func fencePrefix(text string) string {
+	if text == "" || (text[0] != '`' && text[0] != '~') {
+		return ""
+	}
+	n := len(text) - len(strings.TrimLeft(text, text[:1]))
+	if n < 3 {
+		return ""
+	}
+	return text[:n]
+}
+
+// unindent removes n spaces from the start of each line of s, reporting false
+// if a line that isn't blank is indented less.
+func unindent(s string, n int) (string, bool) {
+	if n == 0 {
+		return s, true
+	}
+	lines := strings.SplitAfter(s, "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			lines[i] = strings.TrimLeft(l, " ")
+			continue
+		}
+		if len(l) < n || strings.TrimLeft(l[:n], " ") != "" {
+			return "", false
+		}
+		lines[i] = l[n:]
+	}
+	return strings.Join(lines, ""), true
+}
+
+// indent adds n spaces to the start of each line of s that isn't blank.
+func indent(s string, n int) string {
+	if n == 0 {
+		return s
+	}
+	pad := strings.Repeat(" ", n)
+	lines := strings.SplitAfter(s, "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			lines[i] = pad + l
+		}
+	}
+	return strings.Join(lines, "")
+}
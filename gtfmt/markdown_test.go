@@ -0,0 +1,129 @@
+package gtfmt
+
+import (
+	"testing"
+)
+
+func TestFormatMarkdown(t *testing.T) {
+	md := "# Title\n" +
+		"\n" +
+		"```gotemplate\n" +
+		"{{  .A  }}\n" +
+		"```\n" +
+		"\n" +
+		"```go\n" +
+		"x := `{{  .B  }}`\n" +
+		"```\n" +
+		"\n" +
+		"~~~~ tmpl extra\n" +
+		"a {{  .C  }}\n" +
+		"```\n" +
+		"b\n" +
+		"~~~~\n" +
+		"\n" +
+		"- item\n" +
+		"\n" +
+		"  ```go-template\n" +
+		"  {{if  .D}}\n" +
+		"\n" +
+		"  {{  .E  }}{{end}}\n" +
+		"  ```\n" +
+		"\n" +
+		"```tmpl\n" +
+		"{{define \"x\"}}{{  .F  }}{{end}}\n" +
+		"```\n" +
+		"{{  .G  }}\n"
+	expected := "# Title\n" +
+		"\n" +
+		"```gotemplate\n" +
+		"{{.A}}\n" +
+		"```\n" +
+		"\n" +
+		"```go\n" +
+		"x := `{{  .B  }}`\n" +
+		"```\n" +
+		"\n" +
+		"~~~~ tmpl extra\n" +
+		"a {{.C}}\n" +
+		"```\n" +
+		"b\n" +
+		"~~~~\n" +
+		"\n" +
+		"- item\n" +
+		"\n" +
+		"  ```go-template\n" +
+		"  {{if .D}}\n" +
+		"\n" +
+		"  {{.E}}{{end}}\n" +
+		"  ```\n" +
+		"\n" +
+		"```tmpl\n" +
+		"{{define \"x\"}}{{  .F  }}{{end}}\n" +
+		"```\n" +
+		"{{  .G  }}\n"
+	s, err := FormatMarkdown("doc.md", md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+}
+
+func TestFormatMarkdownErrors(t *testing.T) {
+	md := "Text\n" +
+		"\n" +
+		"```tmpl\n" +
+		"{{  .A  }}\n" +
+		"{{ .B ) }}\n" +
+		"```\n" +
+		"\n" +
+		"  ```tmpl\n" +
+		"  ok\n" +
+		"  {{ if }}\n" +
+		"  ```\n" +
+		"\n" +
+		"```tmpl\n" +
+		"{{  .C  }}\n" +
+		"```\n"
+	expected := "Text\n" +
+		"\n" +
+		"```tmpl\n" +
+		"{{  .A  }}\n" +
+		"{{ .B ) }}\n" +
+		"```\n" +
+		"\n" +
+		"  ```tmpl\n" +
+		"  ok\n" +
+		"  {{ if }}\n" +
+		"  ```\n" +
+		"\n" +
+		"```tmpl\n" +
+		"{{.C}}\n" +
+		"```\n"
+	s, err := FormatMarkdown("doc.md", md)
+	if s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	errs, ok := err.(BlockErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 block errors but got %#v", err)
+	}
+	positions := []struct {
+		line int
+		pos  int
+	}{
+		{5, 31},
+		{10, 64},
+	}
+	for i, p := range positions {
+		perr, ok := errs[i].(*Error)
+		if !ok {
+			t.Errorf("error %d: expected an *Error but got %#v", i, errs[i])
+			continue
+		}
+		if perr.Line != p.line || perr.Offset != p.pos {
+			t.Errorf("error %d: expected line %d, pos %d but got line %d, pos %d (%v)", i, p.line, p.pos, perr.Line, perr.Offset, perr)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package gtfmt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// LineRange is an inclusive range of 1-based line numbers.
+type LineRange struct {
+	First int
+	Last  int
+}
+
+// FormatRange formats only the actions in tpl whose position falls within the
+// byte range [start, end). Everything outside those actions, including
+// whitespace trim markers and comments, is left byte-for-byte identical.
+func FormatRange(name, tpl string, start, end int) (string, error) {
+	return formatSelected(name, tpl, func(pos int) bool {
+		return pos >= start && pos < end
+	})
+}
+
+// FormatActions reformats every action in tpl, including those in templates
+// declared with define and block, leaving the text between them and their
+// trim markers byte-for-byte identical. Unlike Format, it keeps the
+// whitespace that trim markers remove, so the layout of whitespace sensitive
+// output such as YAML is stable.
+func FormatActions(name, tpl string) (string, error) {
+	return FormatDelims(name, tpl, "", "")
+}
+
+// FormatDelims is like FormatActions, but for templates whose actions are
+// delimited by leftDelim and rightDelim rather than {{ and }}. Empty
+// delimiters are the defaults.
+func FormatDelims(name, tpl, leftDelim, rightDelim string) (string, error) {
+	f := &Formatter{opts: Options{LeftDelim: leftDelim, RightDelim: rightDelim, KeepSpace: true}}
+	return f.format(name, tpl)
+}
+
+// FormatPartial is like FormatActions, but formats each action from its
+// tokens alone, so tpl need not parse: blocks may be left open or closed
+// too often, as they are while a template is being written. Actions that
+// don't lex are left as they are.
+func FormatPartial(name, tpl string) (string, error) {
+	f := &Formatter{opts: Options{KeepSpace: true, Partial: true}}
+	return f.format(name, tpl)
+}
+
+// partialEdits returns the edits that format each action of tpl that lexes
+// from its tokens alone.
+func partialEdits(tpl, leftDelim, rightDelim string) []TextEdit {
+	var edits []TextEdit
+	for _, a := range parse.TokenActions("", tpl, leftDelim, rightDelim) {
+		text := a.Left + a.Printed + a.Right
+		if text != tpl[a.Pos:a.End] {
+			edits = append(edits, TextEdit{Start: int(a.Pos), End: int(a.End), NewText: text})
+		}
+	}
+	return edits
+}
+
+// FormatLines is like FormatRange, but formats the actions that start on any
+// of the given lines.
+func FormatLines(name, tpl string, lines ...LineRange) (string, error) {
+	return formatSelected(name, tpl, onLines(tpl, lines))
+}
+
+// onLines returns a function reporting whether an offset in tpl is on any of
+// the given lines.
+func onLines(tpl string, lines []LineRange) func(pos int) bool {
+	var offsets [][2]int
+	for _, r := range lines {
+		start, end := lineOffsets(tpl, r.First, r.Last)
+		offsets = append(offsets, [2]int{start, end})
+	}
+	return func(pos int) bool {
+		for _, o := range offsets {
+			if pos >= o[0] && pos < o[1] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// lineOffsets returns the byte range covering lines first through last of tpl.
+func lineOffsets(tpl string, first, last int) (start, end int) {
+	start, end = len(tpl), len(tpl)
+	line := 1
+	for i := 0; ; {
+		if line == first {
+			start = i
+		}
+		nl := strings.IndexByte(tpl[i:], '\n')
+		if nl < 0 {
+			break
+		}
+		i += nl + 1
+		if line == last {
+			end = i
+			break
+		}
+		line++
+	}
+	if first < 1 {
+		start = 0
+	}
+	return start, end
+}
+
+// formatSelected reprints each action in tpl for which selected returns true,
+// keeping the rest of the text as is.
+func formatSelected(name, tpl string, selected func(pos int) bool) (string, error) {
+	edits, err := selectedEdits(name, tpl, selected)
+	if err != nil {
+		return "", err
+	}
+	return Apply(tpl, edits)
+}
+
+// selectedEdits returns the edits that reprint each action in tpl for which
+// selected returns true.
+func selectedEdits(name, tpl string, selected func(pos int) bool) ([]TextEdit, error) {
+	trees, err := parse.ParseNoFuncs(name, tpl, "", "")
+	if err != nil {
+		return nil, newError(name, tpl, err)
+	}
+	if len(trees) > 1 {
+		return nil, fmt.Errorf("%v: sub templates not currently supported", name)
+	}
+	return actionEdits(name, tpl, "", "", trees, selected, false)
+}
+
+// actionEdits returns the edits that reprint each action in tpl, parsed into
+// trees, for which selected returns true. If whole is set, the edits make tpl
+// print as the root of the tree does, as Format does: trim markers are
+// removed along with the whitespace they trim, comments are removed and
+// {{else if}} is written as an {{if}} nested in an {{else}}. Otherwise only
+// the text between the delimiters of an action is changed.
+func actionEdits(name, tpl, leftDelim, rightDelim string, trees map[string]*parse.Tree, selected func(pos int) bool, whole bool) ([]TextEdit, error) {
+	actions, err := parse.Actions(name, tpl, leftDelim, rightDelim)
+	if err != nil {
+		return nil, newError(name, tpl, err)
+	}
+	actions = withComments(tpl, actions, leftDelim, rightDelim)
+	p := &printer{
+		text:     tpl,
+		actions:  actions,
+		selected: selected,
+		whole:    whole,
+		repl:     map[int]string{},
+	}
+	p.blocks()
+	for _, tree := range trees {
+		p.walk(tree.Root)
+	}
+
+	var edits []TextEdit
+	last := 0
+	for i, a := range actions {
+		start, end := int(a.Pos), int(a.End)
+		text, ok := p.repl[i]
+		switch {
+		case whole:
+			// Actions with no node of their own, comments, print nothing.
+			if strings.HasSuffix(a.Left, "- ") {
+				start -= len(tpl[last:start]) - len(strings.TrimRight(tpl[last:start], spaceChars))
+			}
+			if strings.HasPrefix(a.Right, " -") {
+				next := len(tpl)
+				if i+1 < len(actions) {
+					next = int(actions[i+1].Pos)
+				}
+				end += len(tpl[end:next]) - len(strings.TrimLeft(tpl[end:next], spaceChars))
+			}
+		case !ok:
+			// else, end and define actions have no node of their own in
+			// the tree.
+			kw := strings.TrimSpace(a.Body(tpl))
+			if !selected(int(a.Pos)) {
+				continue
+			}
+			switch {
+			case kw == "else", kw == "end":
+				text = kw
+			case strings.HasPrefix(kw, "define") && strings.TrimLeft(kw[len("define"):], spaceChars) != kw[len("define"):]:
+				text = "define " + strings.TrimSpace(kw[len("define"):])
+			default:
+				continue
+			}
+			text = a.Left + text + a.Right
+		}
+		last = end
+		if text != tpl[start:end] {
+			edits = append(edits, TextEdit{Start: start, End: end, NewText: text})
+		}
+	}
+	return edits, nil
+}
+
+// withComments returns actions, which tpl was lexed into, along with the
+// comments in tpl, in lexical order.
+func withComments(tpl string, actions []parse.Action, leftDelim, rightDelim string) []parse.Action {
+	if leftDelim == "" {
+		leftDelim = "{{"
+	}
+	if rightDelim == "" {
+		rightDelim = "}}"
+	}
+	var all []parse.Action
+	last := 0
+	for i := 0; i <= len(actions); i++ {
+		next := len(tpl)
+		if i < len(actions) {
+			next = int(actions[i].Pos)
+		}
+		// Since tpl lexed, any delimiter between actions opens a comment.
+		for {
+			x := strings.Index(tpl[last:next], leftDelim)
+			if x < 0 {
+				break
+			}
+			c := parse.Action{Pos: parse.Pos(last + x), Left: leftDelim}
+			if strings.HasPrefix(tpl[last+x+len(leftDelim):], "- ") {
+				c.Left += "- "
+			}
+			end := last + x + len(c.Left) + len("/*")
+			end += strings.Index(tpl[end:], "*/") + len("*/")
+			c.Right = rightDelim
+			if strings.HasPrefix(tpl[end:], " -") {
+				c.Right = " -" + rightDelim
+			}
+			c.End = parse.Pos(end + len(c.Right))
+			all = append(all, c)
+			last = int(c.End)
+		}
+		if i < len(actions) {
+			all = append(all, actions[i])
+			last = int(actions[i].End)
+		}
+	}
+	return all
+}
+
+// spaceChars are the characters that trim markers trim.
+const spaceChars = " \t\r\n"
+
+// printer maps the nodes of a parsed template back onto the actions they were
+// parsed from, recording the new text of each selected action.
+type printer struct {
+	text     string
+	actions  []parse.Action
+	selected func(pos int) bool
+	whole    bool           // print actions as the tree does
+	repl     map[int]string // new text, with delimiters, by index into actions
+
+	owner map[int]int   // the action opening the block of each else and end action
+	elses map[int][]int // the else actions of each block, by its opening action
+	ends  map[int]int   // the end action of each block, by its opening action
+}
+
+// blocks matches up the actions that open blocks with their else and end
+// actions.
+func (p *printer) blocks() {
+	p.owner, p.elses, p.ends = map[int]int{}, map[int][]int{}, map[int]int{}
+	var open []int
+	for i, a := range p.actions {
+		switch keyword(a.Body(p.text)) {
+		case "if", "range", "with", "define", "block":
+			open = append(open, i)
+		case "else":
+			if len(open) > 0 {
+				o := open[len(open)-1]
+				p.owner[i] = o
+				p.elses[o] = append(p.elses[o], i)
+			}
+		case "end":
+			if len(open) > 0 {
+				o := open[len(open)-1]
+				open = open[:len(open)-1]
+				p.owner[i] = o
+				p.ends[o] = i
+			}
+		}
+	}
+}
+
+// keyword returns the identifier at the start of the body of an action.
+func keyword(body string) string {
+	body = strings.TrimLeft(body, spaceChars)
+	end := strings.IndexFunc(body, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if end < 0 {
+		return body
+	}
+	return body[:end]
+}
+
+// action returns the index of the action containing pos.
+func (p *printer) action(pos parse.Pos) int {
+	return sort.Search(len(p.actions), func(i int) bool {
+		return p.actions[i].End > pos
+	})
+}
+
+// set records body as the formatted text of the action containing node.
+func (p *printer) set(node parse.Node, body string) {
+	pos := node.Position()
+	if !p.selected(int(pos)) {
+		return
+	}
+	i := p.action(pos)
+	if p.whole {
+		p.repl[i] = "{{" + body + "}}"
+	} else {
+		p.repl[i] = p.actions[i].Left + body + p.actions[i].Right
+	}
+}
+
+func (p *printer) walk(node parse.Node) {
+	if node == nil {
+		return
+	}
+	switch node := node.(type) {
+	case *parse.TextNode:
+		// nothing to do
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Nodes {
+			p.walk(n)
+		}
+	case *parse.ActionNode:
+		p.set(node, node.Pipe.String())
+	case *parse.IfNode:
+		p.walkBranch("if", node.BranchNode)
+	case *parse.RangeNode:
+		p.walkBranch("range", node.BranchNode)
+	case *parse.WithNode:
+		p.walkBranch("with", node.BranchNode)
+	case *parse.TemplateNode:
+		s := node.String()
+		s = s[len("{{") : len(s)-len("}}")]
+		// {{block}} is parsed as a call of the template it defines.
+		a := p.actions[p.action(node.Pos)]
+		if strings.HasPrefix(strings.TrimSpace(a.Body(p.text)), "block") {
+			s = "block" + strings.TrimPrefix(s, "template")
+		}
+		p.set(node, s)
+	default:
+		panic(fmt.Sprintf("unknown node: %T", node))
+	}
+}
+
+func (p *printer) walkBranch(name string, node parse.BranchNode) {
+	body := name + " " + node.Pipe.String()
+	// {{else if}} is folded into a single action with the else keyword.
+	b := p.action(node.Pos)
+	elseIf := keyword(p.actions[b].Body(p.text)) == "else"
+	if elseIf {
+		body = "else " + body
+	}
+	p.set(&node, body)
+	if p.whole {
+		p.branchEnds(b, elseIf, node.ElseList != nil)
+	}
+	p.walk(node.List)
+	p.walk(node.ElseList)
+}
+
+// branchEnds records the text of the else and end actions of the branch
+// opened by action b, which is an {{else if}} if elseIf is set, as the tree
+// prints them.
+func (p *printer) branchEnds(b int, elseIf, hasElse bool) {
+	if elseIf {
+		p.repl[b] = "{{else}}{{" + p.repl[b][len("{{else "):]
+	}
+	o := b
+	if elseIf {
+		o = p.owner[b]
+	}
+	for _, e := range p.elses[o] {
+		if e <= b {
+			continue
+		}
+		// A following {{else if}} is printed by its own node.
+		if strings.TrimSpace(p.actions[e].Body(p.text)) == "else" {
+			if hasElse {
+				p.repl[e] = "{{else}}"
+			} else {
+				p.repl[e] = ""
+			}
+		}
+		break
+	}
+	if o == b {
+		// The tree ends each {{if}} nested by {{else if}} separately.
+		n := 1
+		for _, e := range p.elses[o] {
+			if strings.TrimSpace(p.actions[e].Body(p.text)) != "else" {
+				n++
+			}
+		}
+		p.repl[p.ends[o]] = strings.Repeat("{{end}}", n)
+	}
+}
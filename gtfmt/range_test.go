@@ -0,0 +1,108 @@
+package gtfmt
+
+import (
+	"testing"
+)
+
+func TestFormatRange(t *testing.T) {
+	tpl := `{{  .A  }} {{  .B  }} {{  .C  }}`
+	out, err := FormatRange("tpl", tpl, 11, 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{{  .A  }} {{.B}} {{  .C  }}`
+	if out != expected {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, out)
+	}
+}
+
+func TestFormatLines(t *testing.T) {
+	tpl := `{{  if  .A  }}
+  {{-  .B  }}
+{{  else if  .C }}
+  {{  template  "foo"  . }} {{/* keep   me */}}
+{{  end -}}
+`
+	out, err := FormatLines("tpl", tpl, LineRange{First: 2, Last: 3}, LineRange{First: 5, Last: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{{  if  .A  }}
+  {{- .B}}
+{{else if .C}}
+  {{  template  "foo"  . }} {{/* keep   me */}}
+{{end -}}
+`
+	if out != expected {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, out)
+	}
+}
+
+func TestFormatLinesAll(t *testing.T) {
+	tpl := "{{range $i, $v := .X}}{{ $v }}{{ else }}none{{end}}\n"
+	out, err := FormatLines("tpl", tpl, LineRange{First: 1, Last: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "{{range $i, $v := .X}}{{$v}}{{else}}none{{end}}\n"
+	if out != expected {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, out)
+	}
+}
+
+func TestFormatActions(t *testing.T) {
+	tpl := `{{- define  "app.labels" -}}
+app: {{  .Chart.Name  }}
+{{- end }}
+{{ block  "b"  . }}x{{ end }}
+metadata:
+  labels:
+    {{- include  "app.labels"  . | nindent 4 }}
+{{- if  .Values.x }}
+  a: {{ .Values.x|quote }}
+{{- else if  .Values.y}}
+{{- end }}
+`
+	expected := `{{- define "app.labels" -}}
+app: {{.Chart.Name}}
+{{- end}}
+{{block "b" .}}x{{end}}
+metadata:
+  labels:
+    {{- include "app.labels" . | nindent 4}}
+{{- if .Values.x}}
+  a: {{.Values.x | quote}}
+{{- else if .Values.y}}
+{{- end}}
+`
+	s, err := FormatActions("deployment.yaml", tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+}
+
+func TestFormatPartial(t *testing.T) {
+	tpl := `{{- define  "app.labels" -}}
+{{  else  if  .X}}
+{{  .Chart.Name|quote }} {{/* note */}}
+{{ end }}{{  end  }}{{ "unclosed }}
+{{ include  "app.labels"  . }}`
+	expected := `{{- define "app.labels" -}}
+{{else if .X}}
+{{.Chart.Name | quote}} {{/* note */}}
+{{end}}{{end}}{{ "unclosed }}
+{{include "app.labels" .}}`
+	s, err := FormatPartial("deployment.yaml", tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, s)
+	}
+	if _, err := NewFormatter(Options{Partial: true, Simplify: SimplifyAll}); err == nil {
+		t.Error("expected error for partial formatting with simplifications")
+	}
+}
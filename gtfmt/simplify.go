@@ -0,0 +1,331 @@
+package gtfmt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// Simplification is a set of rewrites that Simplify may make. Each rewrite
+// leaves the output of the template unchanged.
+type Simplification uint
+
+const (
+	// SimplifyWith rewrites an if whose body only uses the value it tests as
+	// a with: {{if .X}}{{.X.Y}}{{end}} becomes {{with .X}}{{.Y}}{{end}}.
+	SimplifyWith Simplification = 1 << iota
+	// SimplifyParens removes parentheses around a single value or command,
+	// e.g. {{printf "%d" (.X)}} becomes {{printf "%d" .X}}.
+	SimplifyParens
+	// SimplifyPrintf removes printf "%s" and printf "%v" from a value that
+	// is known to be a string, e.g. {{printf "%s" (html .X)}} becomes
+	// {{html .X}}. It is not applied to fields and variables, since printf
+	// formats values other than strings differently.
+	SimplifyPrintf
+	// SimplifyNot removes double negation in the condition of an if:
+	// {{if not (not .X)}} becomes {{if .X}}.
+	SimplifyNot
+	// SimplifyElse removes empty else branches.
+	SimplifyElse
+
+	// SimplifyAll makes all of the simplifications.
+	SimplifyAll = SimplifyWith | SimplifyParens | SimplifyPrintf | SimplifyNot | SimplifyElse
+)
+
+// simplifications maps the name of each simplification to its value.
+var simplifications = map[string]Simplification{
+	"if-with":    SimplifyWith,
+	"parens":     SimplifyParens,
+	"printf":     SimplifyPrintf,
+	"not-not":    SimplifyNot,
+	"empty-else": SimplifyElse,
+}
+
+// ParseSimplification parses a comma separated list of simplification names:
+// if-with, parens, printf, not-not and empty-else.
+func ParseSimplification(s string) (Simplification, error) {
+	var simp Simplification
+	for _, name := range strings.Split(s, ",") {
+		v, ok := simplifications[strings.TrimSpace(name)]
+		if !ok {
+			var names []string
+			for name := range simplifications {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return 0, fmt.Errorf("unknown simplification %q, must be one of %s", name, strings.Join(names, ", "))
+		}
+		simp |= v
+	}
+	return simp, nil
+}
+
+// Simplify formats tpl like Format, also making the given simplifications.
+func Simplify(name, tpl string, simp Simplification) (string, error) {
+	f := &Formatter{opts: Options{Simplify: simp}}
+	return f.format(name, tpl)
+}
+
+type simplifier Simplification
+
+func (s simplifier) on(simp Simplification) bool {
+	return Simplification(s)&simp != 0
+}
+
+func (s simplifier) list(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for i, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.ActionNode:
+			s.pipe(n.Pipe)
+		case *parse.IfNode:
+			s.branch(&n.BranchNode)
+			if s.on(SimplifyNot) {
+				s.notNot(n.Pipe)
+			}
+			if s.on(SimplifyWith) && ifToWith(&n.BranchNode) {
+				b := n.BranchNode
+				b.NodeType = parse.NodeWith
+				list.Nodes[i] = &parse.WithNode{BranchNode: b}
+			}
+		case *parse.RangeNode:
+			s.branch(&n.BranchNode)
+		case *parse.WithNode:
+			s.branch(&n.BranchNode)
+		case *parse.TemplateNode:
+			if n.Pipe != nil {
+				s.pipe(n.Pipe)
+			}
+		}
+	}
+}
+
+func (s simplifier) branch(b *parse.BranchNode) {
+	s.pipe(b.Pipe)
+	s.list(b.List)
+	s.list(b.ElseList)
+	if s.on(SimplifyElse) && b.ElseList != nil && len(b.ElseList.Nodes) == 0 {
+		b.ElseList = nil
+	}
+}
+
+func (s simplifier) pipe(pipe *parse.PipeNode) {
+	for i, cmd := range pipe.Cmds {
+		for j, arg := range cmd.Args {
+			switch arg := arg.(type) {
+			case *parse.PipeNode:
+				s.pipe(arg)
+			case *parse.ChainNode:
+				if p, ok := arg.Node.(*parse.PipeNode); ok {
+					s.pipe(p)
+				}
+			}
+			if j > 0 && s.on(SimplifyParens) {
+				cmd.Args[j] = unparen(cmd.Args[j])
+			}
+		}
+		// Commands after the first are passed the previous value as a
+		// final argument, so only the first can be replaced.
+		if i > 0 {
+			continue
+		}
+		if s.on(SimplifyPrintf) && isStringPrintf(cmd) {
+			cmd.Args = cmd.Args[2:]
+		}
+		if s.on(SimplifyParens) && len(cmd.Args) == 1 {
+			if inner := single(cmd.Args[0]); inner != nil {
+				cmd.Args = inner.Args
+			}
+		}
+	}
+}
+
+// single returns the only command of node, if it is a parenthesized pipeline
+// with one command that declares no variables.
+func single(node parse.Node) *parse.CommandNode {
+	p, ok := node.(*parse.PipeNode)
+	if !ok || len(p.Decl) > 0 || len(p.Cmds) != 1 {
+		return nil
+	}
+	return p.Cmds[0]
+}
+
+// unparen returns the value inside the parentheses of an argument such as
+// (.X), or arg if it is not a single parenthesized value.
+func unparen(arg parse.Node) parse.Node {
+	for {
+		cmd := single(arg)
+		if cmd == nil || len(cmd.Args) != 1 {
+			return arg
+		}
+		// nil is a valid argument but not a valid command.
+		if _, ok := cmd.Args[0].(*parse.NilNode); ok {
+			return arg
+		}
+		arg = cmd.Args[0]
+	}
+}
+
+// stringFuncs are the builtin functions that return a string.
+var stringFuncs = map[string]bool{
+	"html":     true,
+	"js":       true,
+	"print":    true,
+	"printf":   true,
+	"println":  true,
+	"urlquery": true,
+}
+
+// isStringPrintf reports whether cmd is printf "%s" or printf "%v" of a
+// string.
+func isStringPrintf(cmd *parse.CommandNode) bool {
+	if len(cmd.Args) != 3 || !isIdent(cmd.Args[0], "printf") {
+		return false
+	}
+	format, ok := cmd.Args[1].(*parse.StringNode)
+	if !ok || (format.Text != "%s" && format.Text != "%v") {
+		return false
+	}
+	switch arg := cmd.Args[2].(type) {
+	case *parse.StringNode:
+		return true
+	case *parse.PipeNode:
+		if inner := single(arg); inner != nil {
+			id, ok := inner.Args[0].(*parse.IdentifierNode)
+			return ok && stringFuncs[id.Ident]
+		}
+	}
+	return false
+}
+
+func isIdent(node parse.Node, name string) bool {
+	id, ok := node.(*parse.IdentifierNode)
+	return ok && id.Ident == name
+}
+
+// notNot replaces not (not X) with X in the condition of an if, where only the
+// truth of the value matters.
+func (s simplifier) notNot(pipe *parse.PipeNode) {
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 {
+		return
+	}
+	cmd := pipe.Cmds[0]
+	if len(cmd.Args) != 2 || !isIdent(cmd.Args[0], "not") {
+		return
+	}
+	inner := single(cmd.Args[1])
+	if inner == nil || len(inner.Args) != 2 || !isIdent(inner.Args[0], "not") {
+		return
+	}
+	if c := single(inner.Args[1]); c != nil {
+		pipe.Cmds[0] = c
+		return
+	}
+	cmd.Args = inner.Args[1:]
+}
+
+// ifToWith reports whether the if b can be rewritten as a with, and if so,
+// rewrites the uses of its value in its body relative to dot.
+func ifToWith(b *parse.BranchNode) bool {
+	if len(b.Pipe.Decl) > 0 || len(b.Pipe.Cmds) != 1 || len(b.Pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	field, ok := b.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok {
+		return false
+	}
+	uses := 0
+	safe := true
+	visitDot(b.List, func(cmd *parse.CommandNode, final bool, i int) {
+		switch arg := cmd.Args[i].(type) {
+		case *parse.DotNode:
+			safe = false
+		case *parse.FieldNode:
+			if !hasPrefix(arg.Ident, field.Ident) {
+				safe = false
+			} else if len(arg.Ident) == len(field.Ident) && i == 0 && (len(cmd.Args) > 1 || final) {
+				// .X may be a method called with arguments.
+				safe = false
+			}
+			uses++
+		}
+	})
+	if !safe || uses == 0 {
+		return false
+	}
+	visitDot(b.List, func(cmd *parse.CommandNode, _ bool, i int) {
+		arg := cmd.Args[i].(*parse.FieldNode)
+		if len(arg.Ident) == len(field.Ident) {
+			cmd.Args[i] = &parse.DotNode{NodeType: parse.NodeDot, Pos: arg.Pos}
+			return
+		}
+		arg.Ident = arg.Ident[len(field.Ident):]
+	})
+	return true
+}
+
+func hasPrefix(ident, prefix []string) bool {
+	if len(ident) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if ident[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// visitDot calls f for each argument in list that is evaluated with the same
+// dot as list, that is a dot or a field, passing the command, whether the
+// command is passed the previous value of its pipeline, and the index of the
+// argument.
+func visitDot(list *parse.ListNode, f func(cmd *parse.CommandNode, final bool, i int)) {
+	if list == nil {
+		return
+	}
+	for _, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.ActionNode:
+			visitPipe(n.Pipe, f)
+		case *parse.IfNode:
+			visitPipe(n.Pipe, f)
+			visitDot(n.List, f)
+			visitDot(n.ElseList, f)
+		case *parse.RangeNode:
+			// The body of a range or with has a dot of its own.
+			visitPipe(n.Pipe, f)
+			visitDot(n.ElseList, f)
+		case *parse.WithNode:
+			visitPipe(n.Pipe, f)
+			visitDot(n.ElseList, f)
+		case *parse.TemplateNode:
+			if n.Pipe != nil {
+				visitPipe(n.Pipe, f)
+			}
+		}
+	}
+}
+
+func visitPipe(pipe *parse.PipeNode, f func(cmd *parse.CommandNode, final bool, i int)) {
+	for c, cmd := range pipe.Cmds {
+		for i, arg := range cmd.Args {
+			switch arg := arg.(type) {
+			case *parse.DotNode, *parse.FieldNode:
+				f(cmd, c > 0, i)
+			case *parse.PipeNode:
+				visitPipe(arg, f)
+			case *parse.ChainNode:
+				// Chains on dot or a field are parsed as a single field, so
+				// only parenthesized pipelines need visiting.
+				if p, ok := arg.Node.(*parse.PipeNode); ok {
+					visitPipe(p, f)
+				}
+			}
+		}
+	}
+}
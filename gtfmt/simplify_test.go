@@ -0,0 +1,61 @@
+package gtfmt
+
+import (
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{"with", `{{if .X}}{{.X}} {{.X.Y}}{{end}}`, `{{with .X}}{{.}} {{.Y}}{{end}}`},
+		{"with else", `{{if .X.Y}}{{.X.Y.Z}}{{else}}{{.A}}{{end}}`, `{{with .X.Y}}{{.Z}}{{else}}{{.A}}{{end}}`},
+		{"with nested", `{{if .X}}{{range .X.L}}{{.A}}{{end}}{{template "t" .X}}{{end}}`, `{{with .X}}{{range .L}}{{.A}}{{end}}{{template "t" .}}{{end}}`},
+		{"with other field", `{{if .X}}{{.X}}{{.Y}}{{end}}`, `{{if .X}}{{.X}}{{.Y}}{{end}}`},
+		{"with dot", `{{if .X}}{{.X}}{{template "t" .}}{{end}}`, `{{if .X}}{{.X}}{{template "t" .}}{{end}}`},
+		{"with method", `{{if .X}}{{.X 1}}{{end}}`, `{{if .X}}{{.X 1}}{{end}}`},
+		{"with unused", `{{if .X}}a{{end}}`, `{{if .X}}a{{end}}`},
+		{"parens", `{{printf "%d" (.X) ((len .Y))}}`, `{{printf "%d" .X (len .Y)}}`},
+		{"parens command", `{{(printf "%d" 1)}}`, `{{printf "%d" 1}}`},
+		{"parens nil", `{{printf "%v" (nil)}}`, `{{printf "%v" (nil)}}`},
+		{"parens method", `{{(.X) 1}}`, `{{(.X) 1}}`},
+		{"printf", `{{printf "%s" (html .X)}} {{printf "%v" "a"}}`, `{{html .X}} {{"a"}}`},
+		{"printf field", `{{printf "%s" .X}}`, `{{printf "%s" .X}}`},
+		{"printf piped", `{{.X | printf "%s" "a"}}`, `{{.X | printf "%s" "a"}}`},
+		{"not", `{{if not (not .X)}}a{{end}}{{if not (not (and .A .B))}}b{{end}}`, `{{if .X}}a{{end}}{{if and .A .B}}b{{end}}`},
+		{"not outside if", `{{not (not .X)}}`, `{{not (not .X)}}`},
+		{"else", `{{if .X}}a{{else}}{{end}}{{range .L}}b{{else}}{{end}}`, `{{if .X}}a{{end}}{{range .L}}b{{end}}`},
+		{"combined", `{{if not (not .X)}}{{printf "%s" (print .X)}}{{else}}{{end}}`, `{{with .X}}{{print .}}{{end}}`},
+	}
+	for _, test := range tests {
+		s, err := Simplify("x", test.in, SimplifyAll)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if s != test.out {
+			t.Errorf("%s: expected:\n%q\n\nbut got:\n%q", test.name, test.out, s)
+		}
+	}
+}
+
+func TestSimplifySelected(t *testing.T) {
+	simp, err := ParseSimplification("empty-else, parens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := `{{if .X}}{{.X}}{{else}}{{end}}{{print (.Y)}}`
+	expected := `{{if .X}}{{.X}}{{end}}{{print .Y}}`
+	s, err := Simplify("x", tpl, simp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, s)
+	}
+	if _, err := ParseSimplification("if-with,bogus"); err == nil {
+		t.Fatal("expected error for unknown simplification")
+	}
+}
@@ -0,0 +1,46 @@
+// Package check finds problems in parsed templates that the parser itself
+// does not report.
+package check
+
+import (
+	"sort"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// Finding is a problem found in a template.
+type Finding struct {
+	Pos parse.Pos // byte position of the problem in the template text
+	Msg string
+}
+
+// SortFindings sorts findings by position, keeping the order of findings at
+// the same position.
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Pos < findings[j].Pos
+	})
+}
+
+// sortedTrees returns trees in a stable order.
+func sortedTrees(trees map[string]*parse.Tree) []*parse.Tree {
+	names := make([]string, 0, len(trees))
+	for name := range trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sorted []*parse.Tree
+	for _, name := range names {
+		sorted = append(sorted, trees[name])
+	}
+	return sorted
+}
+
+// roots returns the root nodes of trees in a stable order.
+func roots(trees map[string]*parse.Tree) []*parse.ListNode {
+	var nodes []*parse.ListNode
+	for _, t := range sortedTrees(trees) {
+		nodes = append(nodes, t.Root)
+	}
+	return nodes
+}
@@ -0,0 +1,80 @@
+package check
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// FuncSet is a set of function names that templates may call.
+type FuncSet map[string]bool
+
+// NewFuncSet returns a set holding the text/template builtins and names.
+func NewFuncSet(names ...string) FuncSet {
+	s := FuncSet{}
+	s.Add(builtins...)
+	s.Add(names...)
+	return s
+}
+
+// Add adds names to the set.
+func (s FuncSet) Add(names ...string) {
+	for _, name := range names {
+		s[name] = true
+	}
+}
+
+// AddPreset adds the functions of the named preset to the set.
+func (s FuncSet) AddPreset(name string) error {
+	funcs, ok := presets[name]
+	if !ok {
+		return fmt.Errorf("unknown function preset %q", name)
+	}
+	s.Add(funcs...)
+	return nil
+}
+
+// Presets returns the names of the function presets, sorted.
+func Presets() []string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadFuncs reads a function manifest: one function name per line, ignoring
+// blank lines and lines starting with #.
+func ReadFuncs(r io.Reader) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, scanner.Err()
+}
+
+// UndefinedFuncs returns a finding for every call of a function in trees that
+// is not in funcs.
+func UndefinedFuncs(trees map[string]*parse.Tree, funcs FuncSet) []Finding {
+	var findings []Finding
+	for _, root := range roots(trees) {
+		parse.Inspect(root, func(n parse.Node) bool {
+			if n, ok := n.(*parse.IdentifierNode); ok && !funcs[n.Ident] {
+				findings = append(findings, Finding{Pos: n.Pos, Msg: fmt.Sprintf("function %q not defined", n.Ident)})
+			}
+			return true
+		})
+	}
+	SortFindings(findings)
+	return findings
+}
@@ -0,0 +1,46 @@
+package check
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+func TestUndefinedFuncs(t *testing.T) {
+	text := `{{define "a"}}{{tittle .Name}}{{end}}{{if (lower .X | tittle)}}{{printf "%s" (upper .)}}{{end}}`
+	trees, err := parse.ParseNoFuncs("test", text, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	funcs := NewFuncSet("upper")
+	findings := UndefinedFuncs(trees, funcs)
+	expected := []Finding{
+		{Pos: 16, Msg: `function "tittle" not defined`},
+		{Pos: 43, Msg: `function "lower" not defined`},
+		{Pos: 54, Msg: `function "tittle" not defined`},
+	}
+	if !reflect.DeepEqual(expected, findings) {
+		t.Fatalf("expected:\n%v\n\ngot:\n%v", expected, findings)
+	}
+	if err := funcs.AddPreset("sprig"); err != nil {
+		t.Fatal(err)
+	}
+	if findings := UndefinedFuncs(trees, funcs); len(findings) != 2 {
+		t.Fatalf("expected only tittle to be undefined with sprig, got %v", findings)
+	}
+	if err := funcs.AddPreset("nope"); err == nil {
+		t.Fatal("expected error for unknown preset")
+	}
+}
+
+func TestReadFuncs(t *testing.T) {
+	names, err := ReadFuncs(strings.NewReader("# my funcs\nfoo\n\n  bar  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"foo", "bar"}; !reflect.DeepEqual(expected, names) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+}
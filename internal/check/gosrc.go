@@ -0,0 +1,165 @@
+package check
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// GoFunc is a template function declared in Go code.
+type GoFunc struct {
+	Name string
+	Pos  token.Position // position of the FuncMap key
+}
+
+// GoFuncs parses the Go packages in dirs and returns the keys of template
+// FuncMap literals and of map literals passed to a Funcs method. A directory
+// ending in /... also includes the packages below it, as with the go command.
+func GoFuncs(dirs ...string) ([]GoFunc, error) {
+	fset := token.NewFileSet()
+	var funcs []GoFunc
+	for _, dir := range dirs {
+		pkgDirs, err := expandDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range pkgDirs {
+			pkgs, err := parser.ParseDir(fset, d, notTest, 0)
+			if err != nil {
+				return nil, err
+			}
+			for _, pkg := range pkgs {
+				for _, f := range pkg.Files {
+					funcs = append(funcs, funcMapKeys(fset, f)...)
+				}
+			}
+		}
+	}
+	sort.SliceStable(funcs, func(i, j int) bool {
+		a, b := funcs[i].Pos, funcs[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	return funcs, nil
+}
+
+func notTest(info os.FileInfo) bool {
+	return !strings.HasSuffix(info.Name(), "_test.go")
+}
+
+// expandDir returns dir, or with a /... suffix, dir and every directory below
+// it that may hold a package.
+func expandDir(dir string) ([]string, error) {
+	if dir != "..." && !strings.HasSuffix(dir, "/...") {
+		return []string{dir}, nil
+	}
+	root := strings.TrimSuffix(strings.TrimSuffix(dir, "..."), "/")
+	if root == "" {
+		root = "."
+	}
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs, err
+}
+
+// funcMapKeys returns the string keys of the function maps declared in f.
+func funcMapKeys(fset *token.FileSet, f *ast.File) []GoFunc {
+	var funcs []GoFunc
+	seen := map[*ast.CompositeLit]bool{}
+	add := func(lit *ast.CompositeLit) {
+		if seen[lit] {
+			return
+		}
+		seen[lit] = true
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			key, ok := kv.Key.(*ast.BasicLit)
+			if !ok || key.Kind != token.STRING {
+				continue
+			}
+			name, err := strconv.Unquote(key.Value)
+			if err != nil {
+				continue
+			}
+			funcs = append(funcs, GoFunc{Name: name, Pos: fset.Position(key.Pos())})
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CompositeLit:
+			if isFuncMap(n.Type) {
+				add(n)
+			}
+		case *ast.CallExpr:
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "Funcs" || len(n.Args) != 1 {
+				break
+			}
+			if lit, ok := n.Args[0].(*ast.CompositeLit); ok {
+				add(lit)
+			}
+		}
+		return true
+	})
+	return funcs
+}
+
+// isFuncMap reports whether typ names a FuncMap type, such as
+// template.FuncMap.
+func isFuncMap(typ ast.Expr) bool {
+	switch typ := typ.(type) {
+	case *ast.Ident:
+		return typ.Name == "FuncMap"
+	case *ast.SelectorExpr:
+		return typ.Sel.Name == "FuncMap"
+	}
+	return false
+}
+
+// UsedFuncs adds the names of the functions called in trees to used.
+func UsedFuncs(trees map[string]*parse.Tree, used map[string]bool) {
+	for _, root := range roots(trees) {
+		parse.Inspect(root, func(n parse.Node) bool {
+			if n, ok := n.(*parse.IdentifierNode); ok {
+				used[n.Ident] = true
+			}
+			return true
+		})
+	}
+}
+
+// UnusedFuncs returns the funcs whose names are not in used.
+func UnusedFuncs(funcs []GoFunc, used map[string]bool) []GoFunc {
+	var unused []GoFunc
+	for _, f := range funcs {
+		if !used[f.Name] {
+			unused = append(unused, f)
+		}
+	}
+	return unused
+}
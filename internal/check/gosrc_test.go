@@ -0,0 +1,55 @@
+package check
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+func names(funcs []GoFunc) []string {
+	var s []string
+	for _, f := range funcs {
+		s = append(s, f.Name)
+	}
+	return s
+}
+
+func TestGoFuncs(t *testing.T) {
+	funcs, err := GoFuncs("testdata/funcs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"upper", "lower", "title"}
+	if s := names(funcs); !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, s)
+	}
+	pos := funcs[0].Pos
+	if pos.Filename != filepath.Join("testdata", "funcs", "funcs.go") || pos.Line != 10 || pos.Column != 2 {
+		t.Errorf("unexpected position %v", pos)
+	}
+
+	funcs, err = GoFuncs("testdata/funcs/...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"upper", "lower", "title", "safe"}
+	if s := names(funcs); !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, s)
+	}
+}
+
+func TestUnusedFuncs(t *testing.T) {
+	trees, err := parse.ParseNoFuncs("x", `{{upper .}}{{define "y"}}{{title . | printf "%s"}}{{end}}`, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := map[string]bool{}
+	UsedFuncs(trees, used)
+	funcs := []GoFunc{{Name: "upper"}, {Name: "lower"}, {Name: "title"}}
+	expected := []string{"lower"}
+	if s := names(UnusedFuncs(funcs, used)); !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, s)
+	}
+}
@@ -0,0 +1,266 @@
+package check
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// Template is a template in a set of files: either the top level template of
+// a file, named after its base name as by ParseFiles and ParseGlob, or one
+// declared with {{define}} or {{block}}.
+type Template struct {
+	Name    string
+	File    string
+	Pos     parse.Pos // position of the quoted name; 0 for a top level template
+	Top     bool      // if true, the top level template of File
+	Partial bool      // if true, a top level template only executed by calls
+	Block   bool      // if true, declared with {{block}}
+	Empty   bool      // if true, it has no content, and won't replace another
+	Tree    *parse.Tree
+}
+
+// Call is a {{template}} or {{block}} action, or a call of one of the
+// Graph's CallFuncs with a constant name.
+type Call struct {
+	Name string    // the template called
+	From *Template // the template containing the call
+	File string
+	Pos  parse.Pos // position of the quoted name
+	Data string    // the pipeline passed as dot, or "" if none
+}
+
+// Graph is the graph of the templates in a set of files and the calls
+// between them.
+type Graph struct {
+	Templates []*Template
+	Calls     []*Call
+
+	// CallFuncs maps the names of functions that execute the template named
+	// by their first argument, such as Helm's include, to a function that
+	// returns the name of the template executed for the name passed, so that
+	// calls of them with a string constant count as template calls.
+	CallFuncs map[string]func(name string) string
+	// TopName, if set, returns the name of the top level template of a file,
+	// and whether it is a partial, only executed by calls. By default, it is
+	// named after the file's base name, and isn't a partial.
+	TopName func(file string) (name string, partial bool)
+	// External, if set, reports whether a template is defined outside the
+	// files of the graph, so that calls of it aren't undefined.
+	External func(name string) bool
+}
+
+// Add parses the file and adds its templates and calls to the graph.
+func (g *Graph) Add(file, text string) error {
+	return g.AddDelims(file, text, "", "")
+}
+
+// AddDelims is like Add, but for files whose actions are delimited by
+// leftDelim and rightDelim rather than {{ and }}. Empty delimiters are the
+// defaults.
+func (g *Graph) AddDelims(file, text, leftDelim, rightDelim string) error {
+	name, partial := filepath.Base(file), false
+	if g.TopName != nil {
+		name, partial = g.TopName(file)
+	}
+	trees, err := parse.ParseNoFuncs(name, text, leftDelim, rightDelim)
+	if err != nil {
+		return err
+	}
+	defs, err := parse.Definitions(name, text, leftDelim, rightDelim)
+	if err != nil {
+		return err
+	}
+	pos := map[string]*parse.Definition{}
+	for _, d := range defs {
+		if pos[d.Name] == nil {
+			pos[d.Name] = d
+		}
+	}
+	var templates []*Template
+	var calls []*Call
+	for _, tree := range sortedTrees(trees) {
+		t := &Template{Name: tree.Name, File: file, Empty: parse.IsEmptyTree(tree.Root), Tree: tree}
+		if d := pos[tree.Name]; d != nil && tree.Name != name {
+			t.Pos = d.NamePos
+			t.Block = d.Block
+		} else {
+			t.Top = true
+			t.Partial = partial
+		}
+		// A file that only defines templates has an empty top level template.
+		if t.Top && t.Empty {
+			continue
+		}
+		templates = append(templates, t)
+		parse.Inspect(tree.Root, func(n parse.Node) bool {
+			switch n := n.(type) {
+			case *parse.TemplateNode:
+				c := &Call{Name: n.Name, From: t, File: file, Pos: n.Pos}
+				if n.Pipe != nil {
+					c.Data = n.Pipe.String()
+				}
+				calls = append(calls, c)
+			case *parse.CommandNode:
+				fn, name, ok := parse.TemplateCall(n)
+				if f := g.CallFuncs[fn]; ok && f != nil {
+					c := &Call{Name: f(name.Text), From: t, File: file, Pos: name.Pos}
+					if len(n.Args) > 2 {
+						c.Data = n.Args[2].String()
+					}
+					calls = append(calls, c)
+				}
+			}
+			return true
+		})
+	}
+	sort.SliceStable(templates, func(i, j int) bool { return templates[i].Pos < templates[j].Pos })
+	sort.SliceStable(calls, func(i, j int) bool { return calls[i].Pos < calls[j].Pos })
+	g.Templates = append(g.Templates, templates...)
+	g.Calls = append(g.Calls, calls...)
+	return nil
+}
+
+// callees returns the names of the templates each template calls, in order
+// of the calls.
+func (g *Graph) callees() map[string][]string {
+	calls := map[string][]string{}
+	for _, c := range g.Calls {
+		calls[c.From.Name] = append(calls[c.From.Name], c.Name)
+	}
+	return calls
+}
+
+// Unused returns the templates that can't be reached by a chain of calls
+// from a top level template or from a template named in roots.
+func (g *Graph) Unused(roots ...string) []*Template {
+	reached := map[string]bool{}
+	var queue []string
+	reach := func(name string) {
+		if !reached[name] {
+			reached[name] = true
+			queue = append(queue, name)
+		}
+	}
+	for _, name := range roots {
+		reach(name)
+	}
+	for _, t := range g.Templates {
+		if t.Top && !t.Partial {
+			reach(t.Name)
+		}
+	}
+	calls := g.callees()
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, callee := range calls[name] {
+			reach(callee)
+		}
+	}
+	var unused []*Template
+	for _, t := range g.Templates {
+		if !reached[t.Name] {
+			unused = append(unused, t)
+		}
+	}
+	return unused
+}
+
+// Duplicates returns the templates with the same name as an earlier one,
+// each paired with the first. Empty templates are ignored, since they don't
+// replace a template with content.
+func (g *Graph) Duplicates() [][2]*Template {
+	first := map[string]*Template{}
+	var dups [][2]*Template
+	for _, t := range g.Templates {
+		if t.Empty {
+			continue
+		}
+		if f := first[t.Name]; f != nil {
+			dups = append(dups, [2]*Template{t, f})
+			continue
+		}
+		first[t.Name] = t
+	}
+	return dups
+}
+
+// Undefined returns the calls of templates that aren't defined.
+func (g *Graph) Undefined() []*Call {
+	defined := map[string]bool{}
+	for _, t := range g.Templates {
+		defined[t.Name] = true
+	}
+	var undefined []*Call
+	for _, c := range g.Calls {
+		if !defined[c.Name] && (g.External == nil || !g.External(c.Name)) {
+			undefined = append(undefined, c)
+		}
+	}
+	return undefined
+}
+
+// Cycles returns the sets of templates that call each other recursively,
+// each sorted by name. Executing a template in a cycle recurses until a
+// condition in the templates stops it, or text/template's depth limit is
+// reached.
+func (g *Graph) Cycles() [][]string {
+	calls := g.callees()
+	var names []string
+	for _, t := range g.Templates {
+		names = append(names, t.Name)
+	}
+	// Tarjan's strongly connected components algorithm.
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var cycles [][]string
+	var visit func(name string)
+	visit = func(name string) {
+		index[name] = len(index)
+		low[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+		self := false
+		for _, callee := range calls[name] {
+			if callee == name {
+				self = true
+			}
+			if _, ok := index[callee]; !ok {
+				visit(callee)
+				if low[callee] < low[name] {
+					low[name] = low[callee]
+				}
+			} else if onStack[callee] && index[callee] < low[name] {
+				low[name] = index[callee]
+			}
+		}
+		if low[name] != index[name] {
+			return
+		}
+		var scc []string
+		for {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[n] = false
+			scc = append(scc, n)
+			if n == name {
+				break
+			}
+		}
+		if len(scc) > 1 || self {
+			sort.Strings(scc)
+			cycles = append(cycles, scc)
+		}
+	}
+	for _, name := range names {
+		if _, ok := index[name]; !ok {
+			visit(name)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
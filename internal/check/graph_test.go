@@ -0,0 +1,157 @@
+package check
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	g := &Graph{}
+	files := []struct{ name, text string }{
+		{"dir/page.tmpl", `{{template "header" .}}{{block "main" .}}{{template "missing"}}{{end}}`},
+		{"dir/parts.tmpl", `{{define "header"}}{{template "nav" .}}{{end}}{{define "nav"}}n{{end}}{{define "old"}}{{template "older"}}{{end}}{{define "older"}}o{{end}}`},
+		{"other/parts.tmpl", `{{define "nav"}}n2{{end}}{{define "stub"}}{{end}}`},
+		{"other/page.tmpl", `{{define "email"}}e{{end}}`},
+	}
+	for _, f := range files {
+		if err := g.Add(f.name, f.text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var unused []string
+	for _, tpl := range g.Unused("email") {
+		unused = append(unused, fmt.Sprintf("%s:%d %s", tpl.File, tpl.Pos, tpl.Name))
+	}
+	expected := []string{"dir/parts.tmpl:79 old", "dir/parts.tmpl:122 older", "other/parts.tmpl:34 stub"}
+	if !reflect.DeepEqual(unused, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, unused)
+	}
+
+	var dups []string
+	for _, d := range g.Duplicates() {
+		dups = append(dups, fmt.Sprintf("%s:%d %s, %s:%d", d[0].File, d[0].Pos, d[0].Name, d[1].File, d[1].Pos))
+	}
+	expected = []string{"other/parts.tmpl:9 nav, dir/parts.tmpl:55"}
+	if !reflect.DeepEqual(dups, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, dups)
+	}
+
+	var undefined []string
+	for _, c := range g.Undefined() {
+		undefined = append(undefined, fmt.Sprintf("%s:%d %s from %s", c.File, c.Pos, c.Name, c.From.Name))
+	}
+	expected = []string{"dir/page.tmpl:52 missing from main"}
+	if !reflect.DeepEqual(undefined, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, undefined)
+	}
+}
+
+func TestGraphCycles(t *testing.T) {
+	g := &Graph{}
+	text := `{{template "a" .Foo}}{{define "a"}}{{template "b" .}}{{end}}{{define "b"}}{{if .}}{{template "a" .X}}{{end}}{{end}}` +
+		`{{define "c"}}{{template "c" .Next}}{{end}}{{define "d"}}{{template "a"}}{{end}}`
+	if err := g.Add("page", text); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"a", "b"}, {"c"}}
+	if cycles := g.Cycles(); !reflect.DeepEqual(cycles, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, cycles)
+	}
+	var data []string
+	for _, c := range g.Calls {
+		data = append(data, c.Data)
+	}
+	expectedData := []string{".Foo", ".", ".X", ".Next", ""}
+	if !reflect.DeepEqual(data, expectedData) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expectedData, data)
+	}
+}
+
+func TestGraphInclude(t *testing.T) {
+	text := `{{define "app.name"}}a{{end}}{{define "app.labels"}}name: {{include "app.name" . | quote}}{{end}}` +
+		`{{include "app.labels" .Values}}{{include (print "x") .}}{{include "missing" .}}`
+	for _, include := range []bool{false, true} {
+		g := &Graph{}
+		if include {
+			g.CallFuncs = HelmCallFuncs
+		}
+		if err := g.Add("deployment.yaml", text); err != nil {
+			t.Fatal(err)
+		}
+		var calls []string
+		for _, c := range g.Calls {
+			calls = append(calls, fmt.Sprintf("%d %s from %s with %s", c.Pos, c.Name, c.From.Name, c.Data))
+		}
+		var expected []string
+		if include {
+			expected = []string{
+				`68 app.name from app.labels with .`,
+				`107 app.labels from deployment.yaml with .Values`,
+				`164 missing from deployment.yaml with .`,
+			}
+		}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Errorf("include %v: expected:\n%q\n\nbut got:\n%q", include, expected, calls)
+		}
+	}
+}
+
+func TestHelmTopName(t *testing.T) {
+	for file, expected := range map[string]string{
+		"/src/app/templates/deployment.yaml":            "app/templates/deployment.yaml",
+		"/src/app/templates/sub/cm.yaml":                "app/templates/sub/cm.yaml",
+		"/src/app/charts/sub/templates/deployment.yaml": "sub/templates/deployment.yaml",
+	} {
+		if name, partial := HelmTopName(filepath.FromSlash(file)); name != expected || partial {
+			t.Errorf("%s: expected %q but got %q, %v", file, expected, name, partial)
+		}
+	}
+}
+
+func TestGraphHugo(t *testing.T) {
+	g := &Graph{CallFuncs: HugoCallFuncs, TopName: HugoTopName, External: HugoExternal}
+	files := []struct{ name, text string }{
+		{"site/layouts/_default/baseof.html", `{{partial "head" .}}{{block "main" .}}{{end}}{{template "_internal/opengraph.html" .}}`},
+		{"site/layouts/_default/single.html", `{{define "main"}}{{partialCached "partials/nav.html" .Site}}{{end}}`},
+		{"site/layouts/partials/head.html", `{{partials.Include "missing" .}}`},
+		{"site/layouts/_partials/nav.html", `n`},
+		{"site/layouts/partials/old.html", `o`},
+	}
+	for _, f := range files {
+		if err := g.Add(f.name, f.text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var calls []string
+	for _, c := range g.Calls {
+		calls = append(calls, fmt.Sprintf("%s -> %s", c.From.Name, c.Name))
+	}
+	expected := []string{
+		"_default/baseof.html -> partials/head.html",
+		"_default/baseof.html -> main",
+		"_default/baseof.html -> _internal/opengraph.html",
+		"main -> partials/nav.html",
+		"partials/head.html -> partials/missing.html",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, calls)
+	}
+	var unused []string
+	for _, tpl := range g.Unused() {
+		unused = append(unused, tpl.Name)
+	}
+	expected = []string{"partials/old.html"}
+	if !reflect.DeepEqual(unused, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, unused)
+	}
+	var undefined []string
+	for _, c := range g.Undefined() {
+		undefined = append(undefined, c.Name)
+	}
+	expected = []string{"partials/missing.html"}
+	if !reflect.DeepEqual(undefined, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, undefined)
+	}
+}
@@ -0,0 +1,372 @@
+package check
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// HTML returns findings for patterns that are risky in html/template, found
+// by following the HTML context through the text of each template the way
+// html/template's escaper does, without executing it:
+//
+//   - actions in unquoted attribute values;
+//   - actions in JavaScript that call functions such as safeJS or safeHTML,
+//     whose results html/template does not escape;
+//   - if, with and range actions whose branches end in different contexts,
+//     which html/template rejects when the template is executed.
+//
+// Each template starts in the context of HTML text.
+func HTML(trees map[string]*parse.Tree) []Finding {
+	h := &htmlChecker{}
+	for _, t := range sortedTrees(trees) {
+		h.list(t.Root, htmlContext{})
+	}
+	SortFindings(h.findings)
+	return h.findings
+}
+
+type htmlChecker struct {
+	findings []Finding
+}
+
+func (h *htmlChecker) report(pos parse.Pos, format string, args ...interface{}) {
+	h.findings = append(h.findings, Finding{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// list returns the context at the end of list, starting in c.
+func (h *htmlChecker) list(list *parse.ListNode, c htmlContext) htmlContext {
+	if list == nil {
+		return c
+	}
+	for _, n := range list.Nodes {
+		c = h.node(n, c)
+	}
+	return c
+}
+
+func (h *htmlChecker) node(node parse.Node, c htmlContext) htmlContext {
+	switch node := node.(type) {
+	case *parse.TextNode:
+		return c.next(string(node.Text))
+	case *parse.ActionNode:
+		// Declarations write nothing.
+		if len(node.Pipe.Decl) > 0 {
+			return c
+		}
+		return h.action(node.Pipe, c)
+	case *parse.IfNode:
+		return h.branches(node.BranchNode, c)
+	case *parse.WithNode:
+		return h.branches(node.BranchNode, c)
+	case *parse.RangeNode:
+		body := h.list(node.List, c)
+		if body != c {
+			h.report(node.Pos, "range body ends in %s, not %s where it starts", body, c)
+			return c
+		}
+		if node.ElseList != nil {
+			if end := h.list(node.ElseList, c); end != body {
+				h.report(node.Pos, "range branches end in different contexts: %s and %s", body, end)
+			}
+		}
+		return body
+	}
+	return c
+}
+
+// branches checks that both branches of an if or with end in the same
+// context.
+func (h *htmlChecker) branches(node parse.BranchNode, c htmlContext) htmlContext {
+	name := "if"
+	if node.Type() == parse.NodeWith {
+		name = "with"
+	}
+	then := h.list(node.List, c)
+	els := h.list(node.ElseList, c)
+	if then != els {
+		h.report(node.Pos, "%s branches end in different contexts: %s and %s", name, then, els)
+	}
+	return then
+}
+
+// action checks an action that writes the value of pipe in context c,
+// returning the context after it.
+func (h *htmlChecker) action(pipe *parse.PipeNode, c htmlContext) htmlContext {
+	if c.state == stateBeforeValue || (c.state == stateAttr && c.delim == delimSpace) {
+		h.report(pipe.Pos, "action in unquoted attribute value")
+		if c.state == stateBeforeValue {
+			c.state, c.delim = stateAttr, delimSpace
+		}
+	}
+	if c.inJS() {
+		parse.Inspect(pipe, func(n parse.Node) bool {
+			if id, ok := n.(*parse.IdentifierNode); ok && unescaped(id.Ident) {
+				h.report(id.Pos, "%s in JavaScript is not escaped", id.Ident)
+			}
+			return true
+		})
+	}
+	return c
+}
+
+// unescaped reports whether the named function is conventionally one that
+// marks its result as safe from escaping, such as safeJS or safeHTML.
+func unescaped(name string) bool {
+	return strings.HasPrefix(name, "safe") && len(name) > len("safe")
+}
+
+// htmlState is the part of an HTML document a position is in.
+type htmlState uint8
+
+const (
+	stateText        htmlState = iota // HTML text
+	stateTag                          // in a tag, before an attribute name
+	stateAttrName                     // after an attribute name
+	stateBeforeValue                  // after an attribute name and =
+	stateAttr                         // in an attribute value
+	stateScript                       // in the body of a script element
+	stateStyle                        // in the body of a style element
+	stateRCDATA                       // in the body of a textarea or title element
+	stateComment                      // in an HTML comment
+)
+
+// attrDelim is what ends an attribute value.
+type attrDelim uint8
+
+const (
+	delimNone attrDelim = iota
+	delimDouble
+	delimSingle
+	delimSpace // an unquoted value, ended by a space or >
+)
+
+// attrKind is the kind of content an attribute value holds.
+type attrKind uint8
+
+const (
+	attrNormal attrKind = iota
+	attrURL
+	attrJS
+	attrCSS
+)
+
+// htmlContext is the context of a position in an HTML document.
+type htmlContext struct {
+	state   htmlState
+	delim   attrDelim
+	attr    attrKind
+	element string // script, style, textarea or title, in or after its start tag
+}
+
+func (c htmlContext) String() string {
+	switch c.state {
+	case stateTag:
+		return "a tag"
+	case stateAttrName:
+		return "an attribute name"
+	case stateBeforeValue, stateAttr:
+		kind := map[attrKind]string{attrNormal: "", attrURL: "URL ", attrJS: "JavaScript ", attrCSS: "CSS "}[c.attr]
+		quote := map[attrDelim]string{delimNone: "", delimDouble: "double quoted ", delimSingle: "single quoted ", delimSpace: "unquoted "}[c.delim]
+		return "a " + quote + kind + "attribute value"
+	case stateScript, stateStyle, stateRCDATA:
+		return "<" + c.element + ">"
+	case stateComment:
+		return "an HTML comment"
+	}
+	return "HTML text"
+}
+
+// inJS reports whether c is in JavaScript.
+func (c htmlContext) inJS() bool {
+	return c.state == stateScript || ((c.state == stateAttr || c.state == stateBeforeValue) && c.attr == attrJS)
+}
+
+// next returns the context after the text s, starting in c.
+func (c htmlContext) next(s string) htmlContext {
+	for len(s) > 0 {
+		var n int
+		c, n = c.step(s)
+		s = s[n:]
+	}
+	return c
+}
+
+// step returns the context after the start of s, and the number of bytes it
+// consumed. It may consume nothing if the state changes.
+func (c htmlContext) step(s string) (htmlContext, int) {
+	switch c.state {
+	case stateText:
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			return c, len(s)
+		}
+		if strings.HasPrefix(s[i:], "<!--") {
+			return htmlContext{state: stateComment}, i + len("<!--")
+		}
+		j := i + 1
+		closing := j < len(s) && s[j] == '/'
+		if closing {
+			j++
+		}
+		k := j
+		for k < len(s) && isTagNameByte(s[k]) {
+			k++
+		}
+		if k == j {
+			return c, i + 1
+		}
+		next := htmlContext{state: stateTag}
+		if name := strings.ToLower(s[j:k]); !closing && rawElements[name] {
+			next.element = name
+		}
+		return next, k
+	case stateTag:
+		i := skipSpace(s, 0)
+		switch {
+		case i == len(s):
+			return c, i
+		case s[i] == '>':
+			return bodyContext(c.element), i + 1
+		case s[i] == '/':
+			if strings.HasPrefix(s[i:], "/>") {
+				return htmlContext{}, i + 2
+			}
+			return c, i + 1
+		}
+		k := i
+		for k < len(s) && !isSpace(s[k]) && s[k] != '=' && s[k] != '>' && s[k] != '/' {
+			k++
+		}
+		if k == i {
+			return c, i + 1
+		}
+		return htmlContext{state: stateAttrName, attr: kindOf(s[i:k]), element: c.element}, k
+	case stateAttrName:
+		i := skipSpace(s, 0)
+		if i == len(s) {
+			return c, i
+		}
+		if s[i] == '=' {
+			c.state = stateBeforeValue
+			return c, i + 1
+		}
+		return htmlContext{state: stateTag, element: c.element}, i
+	case stateBeforeValue:
+		i := skipSpace(s, 0)
+		if i == len(s) {
+			return c, i
+		}
+		c.state = stateAttr
+		switch s[i] {
+		case '"':
+			c.delim = delimDouble
+			return c, i + 1
+		case '\'':
+			c.delim = delimSingle
+			return c, i + 1
+		}
+		c.delim = delimSpace
+		return c, i
+	case stateAttr:
+		var i int
+		switch c.delim {
+		case delimDouble:
+			i = strings.IndexByte(s, '"')
+		case delimSingle:
+			i = strings.IndexByte(s, '\'')
+		default:
+			i = strings.IndexAny(s, " \t\n\f\r>")
+		}
+		if i < 0 {
+			return c, len(s)
+		}
+		if c.delim == delimSpace {
+			// Leave the > for the tag to end.
+			return htmlContext{state: stateTag, element: c.element}, i
+		}
+		return htmlContext{state: stateTag, element: c.element}, i + 1
+	case stateScript, stateStyle, stateRCDATA:
+		end := "</" + c.element
+		i := strings.Index(strings.ToLower(s), end)
+		if i < 0 {
+			return c, len(s)
+		}
+		return htmlContext{state: stateTag}, i + len(end)
+	case stateComment:
+		i := strings.Index(s, "-->")
+		if i < 0 {
+			return c, len(s)
+		}
+		return htmlContext{}, i + len("-->")
+	}
+	return c, len(s)
+}
+
+// rawElements are the elements whose bodies are not HTML.
+var rawElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"textarea": true,
+	"title":    true,
+}
+
+// bodyContext returns the context after the start tag of element.
+func bodyContext(element string) htmlContext {
+	switch element {
+	case "script":
+		return htmlContext{state: stateScript, element: element}
+	case "style":
+		return htmlContext{state: stateStyle, element: element}
+	case "textarea", "title":
+		return htmlContext{state: stateRCDATA, element: element}
+	}
+	return htmlContext{}
+}
+
+// urlAttrs are the attributes whose values are URLs.
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"codebase":   true,
+	"data":       true,
+	"formaction": true,
+	"href":       true,
+	"icon":       true,
+	"longdesc":   true,
+	"manifest":   true,
+	"poster":     true,
+	"src":        true,
+	"usemap":     true,
+}
+
+// kindOf returns the kind of value of the named attribute.
+func kindOf(name string) attrKind {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(name, "on"):
+		return attrJS
+	case name == "style":
+		return attrCSS
+	case urlAttrs[name]:
+		return attrURL
+	}
+	return attrNormal
+}
+
+func isTagNameByte(b byte) bool {
+	return b == '-' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\f' || b == '\r'
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,69 @@
+package check
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+func TestHTML(t *testing.T) {
+	tpl := `<a href="{{.URL}}" title={{.Title}} class='{{.C}}'>{{.Text}}</a>
+<img src={{.Src}}>
+<script>var x = {{.X}}; var y = {{.Y | safeJS}};</script>{{safeHTML .Z}}
+<button onclick="f({{safeJS .F}})">
+<p {{if .A}}class="a"{{end}}>{{if .B}}<b>{{else}}<i{{end}}>
+{{range .L}}<li{{end}}
+{{range .M}}<td>{{.}}</td>{{end}}
+<style>p { color: {{.Color}} }</style><!-- {{.Comment}} -->
+{{with .W}}<b title="{{.}}">{{else}}<b title="x>{{end}}`
+	trees, err := parse.ParseNoFuncs("x", tpl, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range HTML(trees) {
+		got = append(got, fmt.Sprintf("%d: %s", f.Pos, f.Msg))
+	}
+	expected := []string{
+		"27: action in unquoted attribute value",
+		"76: action in unquoted attribute value",
+		"123: safeJS in JavaScript is not escaped",
+		"178: safeJS in JavaScript is not escaped",
+		"227: if branches end in different contexts: HTML text and a tag",
+		"261: range body ends in a tag, not HTML text where it starts",
+		"377: with branches end in different contexts: HTML text and a double quoted attribute value",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, got)
+	}
+}
+
+func TestHTMLContext(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected htmlContext
+	}{
+		{"<p>hi", htmlContext{}},
+		{"<a ", htmlContext{state: stateTag}},
+		{"<a href", htmlContext{state: stateAttrName, attr: attrURL}},
+		{"<a href=", htmlContext{state: stateBeforeValue, attr: attrURL}},
+		{`<a href="x`, htmlContext{state: stateAttr, delim: delimDouble, attr: attrURL}},
+		{`<a onclick='x`, htmlContext{state: stateAttr, delim: delimSingle, attr: attrJS}},
+		{`<a style=x`, htmlContext{state: stateAttr, delim: delimSpace, attr: attrCSS}},
+		{`<a title="x>y"`, htmlContext{state: stateTag}},
+		{`<a title=x>`, htmlContext{}},
+		{`<br/>`, htmlContext{}},
+		{"<SCRIPT type=x>if (a < b) {", htmlContext{state: stateScript, element: "script"}},
+		{"<script>x</SCRIPT>", htmlContext{}},
+		{"<textarea><p>", htmlContext{state: stateRCDATA, element: "textarea"}},
+		{"<!-- <a ", htmlContext{state: stateComment}},
+		{"<!-- <a --> 1 < 2", htmlContext{}},
+	}
+	for _, test := range tests {
+		if c := (htmlContext{}).next(test.text); c != test.expected {
+			t.Errorf("%q: expected %+v but got %+v", test.text, test.expected, c)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package check
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// builtins are the functions predefined by text/template.
+var builtins = []string{
+	"and", "call", "eq", "ge", "gt", "html", "index", "js", "le", "len", "lt",
+	"ne", "not", "or", "print", "printf", "println", "slice", "urlquery",
+}
+
+// presets are the function sets of popular template-based tools, by name.
+var presets = map[string][]string{
+	"sprig": sprigFuncs,
+	"helm":  helmFuncs(),
+	"hugo":  hugoFuncs,
+}
+
+// sprigFuncs are the functions of github.com/Masterminds/sprig.
+var sprigFuncs = []string{
+	// dates
+	"ago", "date", "dateInZone", "dateModify", "date_in_zone", "date_modify",
+	"duration", "durationRound", "htmlDate", "htmlDateInZone", "mustDateModify",
+	"mustToDate", "must_date_modify", "now", "toDate", "unixEpoch",
+	// strings
+	"abbrev", "abbrevboth", "adler32sum", "camelcase", "cat", "contains",
+	"hasPrefix", "hasSuffix", "hello", "indent", "initials", "kebabcase",
+	"lower", "nindent", "nospace", "plural", "quote", "randAlpha",
+	"randAlphaNum", "randAscii", "randNumeric", "repeat", "replace",
+	"sha1sum", "sha256sum", "shuffle", "snakecase", "squote", "substr",
+	"swapcase", "title", "toString", "trim", "trimAll", "trimPrefix",
+	"trimSuffix", "trimall", "trunc", "untitle", "upper", "wrap", "wrapWith",
+	// conversions and string lists
+	"atoi", "float64", "int", "int64", "seq", "split", "splitList", "splitn",
+	"toDecimal", "toStrings", "until", "untilStep", "join", "sortAlpha",
+	// math
+	"add", "add1", "add1f", "addf", "biggest", "ceil", "div", "divf", "floor",
+	"max", "maxf", "min", "minf", "mod", "mul", "mulf", "randInt", "round",
+	"sub", "subf",
+	// defaults, encoding and reflection
+	"all", "any", "coalesce", "compact", "deepCopy", "deepEqual", "default",
+	"empty", "fromJson", "kindIs", "kindOf", "mustCompact", "mustDeepCopy",
+	"mustFromJson", "mustToJson", "mustToPrettyJson", "mustToRawJson",
+	"ternary", "toJson", "toPrettyJson", "toRawJson", "typeIs", "typeIsLike",
+	"typeOf", "b32dec", "b32enc", "b64dec", "b64enc",
+	// os and paths
+	"base", "clean", "dir", "env", "expandenv", "ext", "getHostByName",
+	"isAbs", "osBase", "osClean", "osDir", "osExt", "osIsAbs",
+	// dicts and lists
+	"append", "chunk", "concat", "dict", "dig", "first", "get", "has",
+	"hasKey", "initial", "keys", "last", "list", "merge", "mergeOverwrite",
+	"mustAppend", "mustChunk", "mustFirst", "mustHas", "mustInitial",
+	"mustLast", "mustMerge", "mustMergeOverwrite", "mustPrepend", "mustPush",
+	"mustRest", "mustReverse", "mustSlice", "mustUniq", "mustWithout", "omit",
+	"pick", "pluck", "prepend", "push", "rest", "reverse", "set", "tuple",
+	"uniq", "unset", "values", "without",
+	// crypto
+	"bcrypt", "buildCustomCert", "decryptAES", "derivePassword",
+	"encryptAES", "genCA", "genCAWithKey", "genPrivateKey",
+	"genSelfSignedCert", "genSelfSignedCertWithKey", "genSignedCert",
+	"genSignedCertWithKey", "htpasswd", "randBytes", "uuidv4",
+	// semver, regexps, urls and flow control
+	"fail", "semver", "semverCompare", "mustRegexFind", "mustRegexFindAll",
+	"mustRegexMatch", "mustRegexReplaceAll", "mustRegexReplaceAllLiteral",
+	"mustRegexSplit", "regexFind", "regexFindAll", "regexMatch",
+	"regexQuoteMeta", "regexReplaceAll", "regexReplaceAllLiteral",
+	"regexSplit", "urlJoin", "urlParse",
+}
+
+// helmFuncs returns the functions available in Helm charts: sprig without
+// its environment functions, plus Helm's own.
+func helmFuncs() []string {
+	var funcs []string
+	for _, name := range sprigFuncs {
+		if name != "env" && name != "expandenv" {
+			funcs = append(funcs, name)
+		}
+	}
+	return append(funcs,
+		"fromJsonArray", "fromYaml", "fromYamlArray", "include", "lookup",
+		"required", "toToml", "toYaml", "toYamlPretty", "tpl",
+	)
+}
+
+// hugoFuncs are the functions of the Hugo static site generator: its
+// namespaces, which are called as e.g. strings.Title, and their aliases.
+var hugoFuncs = []string{
+	// namespaces
+	"cast", "collections", "compare", "crypto", "css", "data", "debug",
+	"diagrams", "encoding", "fmt", "hash", "hugo", "images", "inflect",
+	"js", "lang", "math", "openapi3", "os", "partials", "path", "reflect",
+	"resources", "safe", "site", "strings", "templates", "time", "transform",
+	"urls",
+	// aliases
+	"absLangURL", "absURL", "add", "after", "anchorize", "append", "apply",
+	"base64Decode", "base64Encode", "chomp", "complement", "countrunes",
+	"countwords", "dateFormat", "default", "delimit", "dict", "div",
+	"echoParam", "emojify", "errorf", "erroridf", "fileExists", "findRE",
+	"findRESubmatch", "first", "float", "getenv", "group", "hasPrefix",
+	"hasSuffix", "highlight", "hmac", "htmlEscape", "htmlUnescape",
+	"humanize", "i18n", "in", "int", "intersect", "isset", "jsonify",
+	"last", "lower", "markdownify", "md5", "merge", "mod", "modBool", "mul",
+	"now", "partial", "partialCached", "plainify", "pluralize", "querify",
+	"readDir", "readFile", "ref", "relLangURL", "relURL", "relref",
+	"replace", "replaceRE", "return", "safeCSS", "safeHTML", "safeHTMLAttr",
+	"safeJS", "safeJSStr", "safeURL", "seq", "sha1", "sha256", "shuffle",
+	"singularize", "slicestr", "sort", "split", "string", "sub", "substr",
+	"symdiff", "T", "title", "trim", "truncate", "try", "union", "uniq",
+	"unmarshal", "upper", "urlize", "warnf", "warnidf", "where",
+}
+
+// HelmCallFuncs are the functions of Helm that execute a template by name.
+var HelmCallFuncs = map[string]func(string) string{
+	"include": func(name string) string { return name },
+}
+
+// HelmTopName returns the name Helm gives the top level template of a file in
+// the templates directory of a chart, its path in the chart prefixed with the
+// chart's directory, such as mychart/templates/deployment.yaml, so that files
+// of the same name in a chart and its subcharts are told apart. A relative
+// path is made absolute to find the chart's directory.
+func HelmTopName(file string) (string, bool) {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	name := filepath.ToSlash(file)
+	if i := strings.LastIndex(name, "/templates/"); i >= 0 {
+		name = path.Base(name[:i]) + name[i:]
+	}
+	return name, false
+}
+
+// HugoCallFuncs are the functions of Hugo that execute a partial template, a
+// file in the partials directory of the layouts, by its path in that
+// directory. The .html extension may be left out.
+var HugoCallFuncs = map[string]func(string) string{
+	"partial":                hugoPartial,
+	"partialCached":          hugoPartial,
+	"partials.Include":       hugoPartial,
+	"partials.IncludeCached": hugoPartial,
+}
+
+// hugoPartial returns the name of the template of the partial name, as named
+// by HugoTopName.
+func hugoPartial(name string) string {
+	name = strings.TrimPrefix(name, "partials/")
+	if path.Ext(name) == "" {
+		name += ".html"
+	}
+	return "partials/" + name
+}
+
+// HugoTopName returns the name Hugo gives the top level template of a file in
+// a layouts directory, its path in that directory, and whether it is a
+// partial. Partials in _partials, where newer versions of Hugo keep them, are
+// named as if they were in partials.
+func HugoTopName(file string) (string, bool) {
+	name := filepath.ToSlash(file)
+	if i := strings.LastIndex("/"+name, "/layouts/"); i >= 0 {
+		name = name[i+len("layouts/"):]
+	}
+	if strings.HasPrefix(name, "_partials/") {
+		name = name[1:]
+	}
+	return name, strings.HasPrefix(name, "partials/")
+}
+
+// HugoExternal reports whether name is one of the templates embedded in Hugo,
+// which are named with the prefix _internal/.
+func HugoExternal(name string) bool {
+	return strings.HasPrefix(name, "_internal/")
+}
@@ -0,0 +1,18 @@
+package funcs
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+func parse(text string) (*htmltemplate.Template, error) {
+	return htmltemplate.New("x").Funcs(map[string]interface{}{
+		"title": strings.Title,
+	}).Parse(text)
+}
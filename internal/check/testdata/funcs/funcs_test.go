@@ -0,0 +1,5 @@
+package funcs
+
+import "text/template"
+
+var testFuncs = template.FuncMap{"ignored": nil}
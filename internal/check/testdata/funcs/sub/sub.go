@@ -0,0 +1,5 @@
+package sub
+
+import "html/template"
+
+var m = template.FuncMap{"safe": func(s string) template.HTML { return template.HTML(s) }}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type Page struct {
+	Title   string
+	Author  *User
+	Posts   []Post
+	Tags    map[string]Tag
+	Extra   interface{}
+	Created time.Time
+	hidden  string
+}
+
+func (p *Page) URL() string { return "" }
+
+type User struct {
+	Name string
+}
+
+type Post struct {
+	Title string
+	By    User
+}
+
+type Tag struct {
+	Count int
+}
@@ -0,0 +1,271 @@
+package check
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// LoadType type-checks a Go package from source and returns one of its types.
+// The type is given as the package's directory or import path and the type
+// name, separated by a dot, e.g. ./models.Page.
+func LoadType(spec string) (types.Type, error) {
+	i := strings.LastIndex(spec, ".")
+	if i <= strings.LastIndex(spec, "/") || i == len(spec)-1 {
+		return nil, fmt.Errorf("type %q must be in the format 'path.Name'", spec)
+	}
+	path, name := spec[:i], spec[i+1:]
+	dir := path
+	if !build.IsLocalImport(path) && !filepath.IsAbs(path) {
+		pkg, err := build.Import(path, ".", build.FindOnly)
+		if err != nil {
+			return nil, err
+		}
+		dir = pkg.Dir
+	}
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, notTest, 0)
+	if err != nil {
+		return nil, err
+	}
+	var pkgNames []string
+	for name := range pkgs {
+		pkgNames = append(pkgNames, name)
+	}
+	if len(pkgNames) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	sort.Strings(pkgNames)
+	var files []*ast.File
+	for _, f := range pkgs[pkgNames[0]].Files {
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check(path, fset, files, nil)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("no type %s in %s", name, path)
+	}
+	return obj.Type(), nil
+}
+
+// Fields returns a finding for every field or method in the template name that
+// doesn't exist on the type of the value it's evaluated on, when the template
+// is executed with data of the given type. Templates invoked with {{template}}
+// are checked in turn with the type of the data passed to them. Nothing is
+// reported on values whose type is not known statically, such as the results
+// of functions or interface values.
+func Fields(trees map[string]*parse.Tree, name string, data types.Type) []Finding {
+	c := &typeChecker{trees: trees, done: map[string]bool{}}
+	c.tree(name, data)
+	SortFindings(c.findings)
+	return c.findings
+}
+
+// typeChecker follows the type of dot and variables through a template. A nil
+// type means the type is unknown.
+type typeChecker struct {
+	trees    map[string]*parse.Tree
+	done     map[string]bool // templates checked, by name and type of dot
+	vars     []typedVar      // variables in scope, innermost last
+	findings []Finding
+}
+
+type typedVar struct {
+	name string
+	typ  types.Type
+}
+
+func (c *typeChecker) tree(name string, dot types.Type) {
+	t := c.trees[name]
+	if t == nil || dot == nil {
+		return
+	}
+	key := name + "\x00" + dot.String()
+	if c.done[key] {
+		return
+	}
+	c.done[key] = true
+	saved := c.vars
+	c.vars = []typedVar{{"$", dot}}
+	c.list(t.Root, dot)
+	c.vars = saved
+}
+
+func (c *typeChecker) list(list *parse.ListNode, dot types.Type) {
+	if list == nil {
+		return
+	}
+	for _, n := range list.Nodes {
+		c.node(n, dot)
+	}
+}
+
+func (c *typeChecker) node(node parse.Node, dot types.Type) {
+	switch node := node.(type) {
+	case *parse.ActionNode:
+		t := c.pipe(node.Pipe, dot)
+		c.declare(node.Pipe.Decl, t)
+	case *parse.IfNode:
+		c.branch(node.BranchNode, dot, c.pipe(node.Pipe, dot), dot)
+	case *parse.WithNode:
+		t := c.pipe(node.Pipe, dot)
+		c.branch(node.BranchNode, dot, t, t)
+	case *parse.RangeNode:
+		mark := len(c.vars)
+		key, elem := rangeTypes(c.pipe(node.Pipe, dot))
+		switch decl := node.Pipe.Decl; len(decl) {
+		case 1:
+			c.declare(decl, elem)
+		case 2:
+			c.declare(decl[:1], key)
+			c.declare(decl[1:], elem)
+		}
+		c.list(node.List, elem)
+		c.list(node.ElseList, dot)
+		c.vars = c.vars[:mark]
+	case *parse.TemplateNode:
+		var t types.Type
+		if node.Pipe != nil {
+			t = c.pipe(node.Pipe, dot)
+		}
+		c.tree(node.Name, t)
+	}
+}
+
+// branch checks the lists of an if or with, declaring the variables of its
+// pipeline, of type t, for both lists.
+func (c *typeChecker) branch(node parse.BranchNode, dot, t, inner types.Type) {
+	mark := len(c.vars)
+	c.declare(node.Pipe.Decl, t)
+	c.list(node.List, inner)
+	c.list(node.ElseList, dot)
+	c.vars = c.vars[:mark]
+}
+
+func (c *typeChecker) declare(decl []*parse.VariableNode, t types.Type) {
+	for _, v := range decl {
+		c.vars = append(c.vars, typedVar{v.Ident[0], t})
+	}
+}
+
+func (c *typeChecker) lookup(name string) types.Type {
+	for i := len(c.vars) - 1; i >= 0; i-- {
+		if c.vars[i].name == name {
+			return c.vars[i].typ
+		}
+	}
+	return nil
+}
+
+// pipe returns the type of the result of pipe.
+func (c *typeChecker) pipe(pipe *parse.PipeNode, dot types.Type) types.Type {
+	if pipe == nil {
+		return nil
+	}
+	var t types.Type
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args[1:] {
+			c.arg(arg, dot)
+		}
+		t = c.arg(cmd.Args[0], dot)
+	}
+	return t
+}
+
+// arg returns the type of an argument or the result of a method it calls.
+func (c *typeChecker) arg(node parse.Node, dot types.Type) types.Type {
+	switch node := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return c.fields(node.Pos, dot, node.Ident)
+	case *parse.VariableNode:
+		return c.fields(node.Pos, c.lookup(node.Ident[0]), node.Ident[1:])
+	case *parse.ChainNode:
+		return c.fields(node.Pos, c.arg(node.Node, dot), node.Field)
+	case *parse.PipeNode:
+		return c.pipe(node, dot)
+	}
+	return nil
+}
+
+func (c *typeChecker) fields(pos parse.Pos, t types.Type, idents []string) types.Type {
+	for _, name := range idents {
+		if t == nil {
+			return nil
+		}
+		t = c.field(pos, t, name)
+	}
+	return t
+}
+
+// field returns the type of the field, method result or map element name of
+// a value of type t, the way text/template evaluates .name.
+func (c *typeChecker) field(pos parse.Pos, t types.Type, name string) types.Type {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	switch obj := obj.(type) {
+	case *types.Var:
+		return obj.Type()
+	case *types.Func:
+		res := obj.Type().(*types.Signature).Results()
+		if res.Len() == 0 {
+			return nil
+		}
+		return res.At(0).Type()
+	}
+	switch u := deref(t).Underlying().(type) {
+	case *types.Map:
+		return u.Elem()
+	case *types.Interface:
+		return nil
+	}
+	c.findings = append(c.findings, Finding{
+		Pos: pos,
+		Msg: fmt.Sprintf("can't evaluate field %s in type %s", name, typeString(t)),
+	})
+	return nil
+}
+
+// rangeTypes returns the types of the keys and elements when ranging over a
+// value of type t.
+func rangeTypes(t types.Type) (key, elem types.Type) {
+	if t == nil {
+		return nil, nil
+	}
+	switch u := deref(t).Underlying().(type) {
+	case *types.Slice:
+		return types.Typ[types.Int], u.Elem()
+	case *types.Array:
+		return types.Typ[types.Int], u.Elem()
+	case *types.Map:
+		return u.Key(), u.Elem()
+	case *types.Chan:
+		return nil, u.Elem()
+	}
+	return nil, nil
+}
+
+// deref returns the type t points to, or t if it is not a pointer.
+func deref(t types.Type) types.Type {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+}
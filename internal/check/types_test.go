@@ -0,0 +1,69 @@
+package check
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+func TestFields(t *testing.T) {
+	typ, err := LoadType("./testdata/models.Page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := `{{.Title}} {{.Titel}} {{.Author.Name}} {{.Author.Nmae}} {{.URL}} {{.hidden}}
+{{.Created.Year}} {{.Created.Yaer}} {{.Extra.Anything}} {{(printf "%s" .Title).Foo}}
+{{with .Author}}{{.Name}}{{.Title}}{{else}}{{.Title}}{{end}}
+{{range $i, $p := .Posts}}{{.By.Name}}{{$p.Titel}}{{$.Author.Name}}{{$.Nope}}{{end}}
+{{range .Tags}}{{.Count}}{{.Cnt}}{{end}}
+{{$u := .Author}}{{$u.Name}}{{$u.Nam}}
+{{template "post" index .Posts 0}}{{template "user" .Author}}
+{{define "user"}}{{.Name}}{{.Email}}{{end}}`
+	trees, err := parse.ParseNoFuncs("page", tpl, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range Fields(trees, "page", typ) {
+		got = append(got, f.Msg)
+	}
+	expected := []string{
+		`can't evaluate field Titel in type models.Page`,
+		`can't evaluate field Nmae in type *models.User`,
+		`can't evaluate field hidden in type models.Page`,
+		`can't evaluate field Yaer in type time.Time`,
+		`can't evaluate field Title in type *models.User`,
+		`can't evaluate field Titel in type models.Post`,
+		`can't evaluate field Nope in type models.Page`,
+		`can't evaluate field Cnt in type models.Tag`,
+		`can't evaluate field Nam in type *models.User`,
+		`can't evaluate field Email in type *models.User`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, got)
+	}
+}
+
+func TestFieldsPos(t *testing.T) {
+	typ, err := LoadType("./testdata/models.User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trees, err := parse.ParseNoFuncs("x", "Hi {{.Name}}\n{{ .Mane }}", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := Fields(trees, "x", typ)
+	if len(findings) != 1 || findings[0].Pos != 16 {
+		t.Fatalf("expected one finding at 16 but got %v", findings)
+	}
+}
+
+func TestLoadTypeErrors(t *testing.T) {
+	for _, spec := range []string{"./testdata/models", "./testdata/models.Nope", "./testdata/nodir.Page"} {
+		if _, err := LoadType(spec); err == nil {
+			t.Errorf("expected error loading %q", spec)
+		}
+	}
+}
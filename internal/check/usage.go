@@ -0,0 +1,189 @@
+package check
+
+import (
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// FieldUse is a use of a path in the data passed to a template, such as .A.B,
+// resolved relative to the data the template set is executed with. Where dot
+// or a variable holds the element of a range, the path has a [] suffix, e.g.
+// .Items[].Name. Where it holds the result of a function, the path starts
+// with the pipeline in parentheses, e.g. (index .M "k").Name.
+type FieldUse struct {
+	Path string
+	File string
+	Pos  parse.Pos
+}
+
+// FieldUses returns the fields, chains and variables used in the templates
+// of g. Top level templates, and templates not called from one, are taken to
+// be executed with the data; other templates are followed from each call with
+// the data passed to them.
+func (g *Graph) FieldUses() []FieldUse {
+	w := &usageWalker{byName: map[string]*Template{}, active: map[string]bool{}}
+	for _, t := range g.Templates {
+		if !t.Empty && w.byName[t.Name] == nil {
+			w.byName[t.Name] = t
+		}
+	}
+	unused := map[*Template]bool{}
+	for _, t := range g.Unused() {
+		unused[t] = true
+	}
+	for _, t := range g.Templates {
+		if (t.Top || unused[t]) && w.byName[t.Name] == t {
+			w.template(t, ".")
+		}
+	}
+	return w.uses
+}
+
+// usageWalker follows the paths held by dot and variables through templates.
+// An empty path is not a path in the data, such as the index of a range.
+type usageWalker struct {
+	byName map[string]*Template
+	active map[string]bool // templates being walked, to stop at recursion
+	file   string
+	vars   []pathVar // variables in scope, innermost last
+	uses   []FieldUse
+}
+
+type pathVar struct {
+	name string
+	path string
+}
+
+func (w *usageWalker) template(t *Template, dot string) {
+	if w.active[t.Name] {
+		return
+	}
+	w.active[t.Name] = true
+	file, vars := w.file, w.vars
+	w.file, w.vars = t.File, []pathVar{{"$", dot}}
+	w.list(t.Tree.Root, dot)
+	w.file, w.vars = file, vars
+	w.active[t.Name] = false
+}
+
+func (w *usageWalker) list(list *parse.ListNode, dot string) {
+	if list == nil {
+		return
+	}
+	for _, n := range list.Nodes {
+		w.node(n, dot)
+	}
+}
+
+func (w *usageWalker) node(node parse.Node, dot string) {
+	switch node := node.(type) {
+	case *parse.ActionNode:
+		w.declare(node.Pipe.Decl, w.pipe(node.Pipe, dot))
+	case *parse.IfNode:
+		mark := len(w.vars)
+		w.declare(node.Pipe.Decl, w.pipe(node.Pipe, dot))
+		w.list(node.List, dot)
+		w.list(node.ElseList, dot)
+		w.vars = w.vars[:mark]
+	case *parse.WithNode:
+		mark := len(w.vars)
+		p := w.pipe(node.Pipe, dot)
+		w.declare(node.Pipe.Decl, p)
+		w.list(node.List, p)
+		w.list(node.ElseList, dot)
+		w.vars = w.vars[:mark]
+	case *parse.RangeNode:
+		mark := len(w.vars)
+		elem := ""
+		if p := w.pipe(node.Pipe, dot); p != "" {
+			elem = p + "[]"
+		}
+		switch decl := node.Pipe.Decl; len(decl) {
+		case 1:
+			w.declare(decl, elem)
+		case 2:
+			w.declare(decl[:1], "")
+			w.declare(decl[1:], elem)
+		}
+		w.list(node.List, elem)
+		w.list(node.ElseList, dot)
+		w.vars = w.vars[:mark]
+	case *parse.TemplateNode:
+		if node.Pipe == nil {
+			break
+		}
+		p := w.pipe(node.Pipe, dot)
+		if t := w.byName[node.Name]; t != nil && p != "" {
+			w.template(t, p)
+		}
+	}
+}
+
+func (w *usageWalker) declare(decl []*parse.VariableNode, path string) {
+	for _, v := range decl {
+		w.vars = append(w.vars, pathVar{v.Ident[0], path})
+	}
+}
+
+func (w *usageWalker) lookup(name string) string {
+	for i := len(w.vars) - 1; i >= 0; i-- {
+		if w.vars[i].name == name {
+			return w.vars[i].path
+		}
+	}
+	return ""
+}
+
+// pipe records the uses in pipe, returning the path of its value.
+func (w *usageWalker) pipe(pipe *parse.PipeNode, dot string) string {
+	path := ""
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			path = w.arg(arg, dot)
+		}
+	}
+	if len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+		return path
+	}
+	return "(" + pipe.String() + ")"
+}
+
+// arg records the uses in arg, returning its path, or "" if it has none.
+func (w *usageWalker) arg(arg parse.Node, dot string) string {
+	switch arg := arg.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return w.use(arg.Pos, join(dot, arg.Ident))
+	case *parse.VariableNode:
+		if len(arg.Ident) == 1 && arg.Ident[0] == "$" {
+			return w.lookup("$")
+		}
+		return w.use(arg.Pos, join(w.lookup(arg.Ident[0]), arg.Ident[1:]))
+	case *parse.ChainNode:
+		return w.use(arg.Pos, join(w.arg(arg.Node, dot), arg.Field))
+	case *parse.PipeNode:
+		return w.pipe(arg, dot)
+	}
+	return ""
+}
+
+// use records a use of path, if it is a path.
+func (w *usageWalker) use(pos parse.Pos, path string) string {
+	if path != "" {
+		w.uses = append(w.uses, FieldUse{Path: path, File: w.file, Pos: pos})
+	}
+	return path
+}
+
+// join returns the path of the fields idents of the value at path.
+func join(path string, idents []string) string {
+	if path == "" || len(idents) == 0 {
+		return path
+	}
+	if path == "." {
+		return "." + strings.Join(idents, ".")
+	}
+	return path + "." + strings.Join(idents, ".")
+}
@@ -0,0 +1,43 @@
+package check
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFieldUses(t *testing.T) {
+	g := &Graph{}
+	page := `{{.Title}}{{with .Author}}{{.Name}}{{end}}{{range $i, $p := .Posts}}{{$p.Title}}{{.By.Name}}{{$i.X}}{{end}}` +
+		`{{$a := .Author}}{{$a.Email}}{{$.Site.Name}}{{(index .M "k").Name}}{{with index .M "k"}}{{.Name}}{{end}}` +
+		`{{template "card" .Featured}}{{define "card"}}{{.Title}}{{template "card" .Next}}{{end}}{{define "loose"}}{{.Footer}}{{end}}`
+	if err := g.Add("page", page); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, u := range g.FieldUses() {
+		got = append(got, fmt.Sprintf("%d %s", u.Pos, u.Path))
+	}
+	expected := []string{
+		"2 .Title",
+		"17 .Author",
+		"28 .Author.Name",
+		"60 .Posts",
+		"70 .Posts[].Title",
+		"82 .Posts[].By.Name",
+		"115 .Author",
+		"126 .Author.Email",
+		"138 .Site.Name",
+		"160 .M",
+		"167 (index .M \"k\").Name",
+		"187 .M",
+		"197 (index .M \"k\").Name",
+		"229 .Featured",
+		"259 .Featured.Title",
+		"285 .Featured.Next",
+		"319 .Footer",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, got)
+	}
+}
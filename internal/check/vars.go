@@ -0,0 +1,239 @@
+package check
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// Vars returns a finding for every variable in trees that is declared but
+// never used, and for every declaration in a nested if, with or range that
+// shadows a variable of the same name in an outer scope.
+func Vars(trees map[string]*parse.Tree) []Finding {
+	var findings []Finding
+	for _, t := range sortedTrees(trees) {
+		s := newVarScopes()
+		s.list(t.Root)
+		for _, d := range s.unused() {
+			findings = append(findings, Finding{
+				Pos: d.node.Pos,
+				Msg: fmt.Sprintf("variable %s declared and not used", d.node.Ident[0]),
+			})
+		}
+		for _, sh := range s.shadows {
+			findings = append(findings, Finding{
+				Pos: sh.node.Pos,
+				Msg: fmt.Sprintf("declaration of %s shadows declaration at line %d", sh.node.Ident[0], line(t, sh.outer.node)),
+			})
+		}
+	}
+	SortFindings(findings)
+	return findings
+}
+
+// RemoveUnusedVars deletes the actions in text that only declare a variable
+// that is never used, returning the new text and the number of actions
+// deleted. An action is only deleted if doing so can't change the output of
+// the template: its value must be a constant, . or a variable, and it must
+// have no trim markers. The actions are delimited by leftDelim and
+// rightDelim; empty delimiters are the defaults, {{ and }}.
+func RemoveUnusedVars(name, text, leftDelim, rightDelim string) (string, int, error) {
+	left, right := leftDelim, rightDelim
+	if left == "" {
+		left = "{{"
+	}
+	if right == "" {
+		right = "}}"
+	}
+	removed := 0
+	for {
+		trees, err := parse.ParseNoFuncs(name, text, leftDelim, rightDelim)
+		if err != nil {
+			return "", 0, err
+		}
+		actions, err := parse.Actions(name, text, leftDelim, rightDelim)
+		if err != nil {
+			return "", 0, err
+		}
+		var del []parse.Action
+		for _, root := range roots(trees) {
+			s := newVarScopes()
+			s.list(root)
+			for _, d := range s.unused() {
+				if d.action == nil || !removable(d.action) {
+					continue
+				}
+				i := sort.Search(len(actions), func(i int) bool {
+					return actions[i].End > d.node.Pos
+				})
+				a := actions[i]
+				if a.Left != left || a.Right != right {
+					continue
+				}
+				del = append(del, a)
+			}
+		}
+		if len(del) == 0 {
+			return text, removed, nil
+		}
+		sort.Slice(del, func(i, j int) bool { return del[i].Pos < del[j].Pos })
+		var buf strings.Builder
+		last := 0
+		for _, a := range del {
+			buf.WriteString(text[last:a.Pos])
+			last = int(a.End)
+		}
+		buf.WriteString(text[last:])
+		text = buf.String()
+		removed += len(del)
+	}
+}
+
+// removable reports whether action declares a single variable whose value
+// is a constant, . or another variable. Evaluating anything else, a field,
+// method or function call, may have side effects or fail at run time, so
+// deleting it could change how the template executes.
+func removable(action *parse.ActionNode) bool {
+	pipe := action.Pipe
+	if len(pipe.Decl) != 1 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch n := pipe.Cmds[0].Args[0].(type) {
+	case *parse.BoolNode, *parse.NumberNode, *parse.StringNode, *parse.NilNode, *parse.DotNode:
+		return true
+	case *parse.VariableNode:
+		return len(n.Ident) == 1
+	}
+	return false
+}
+
+// varDecl is the declaration of a template variable.
+type varDecl struct {
+	node   *parse.VariableNode
+	action *parse.ActionNode // the action declaring it, unless a control structure does
+	depth  int               // the nesting depth of the scope it is declared in
+	used   bool
+	key    bool // if true, it is the key of a range that also declares the element
+}
+
+// shadow is a declaration that hides outer.
+type shadow struct {
+	node  *parse.VariableNode
+	outer *varDecl
+}
+
+// varScopes tracks the variables in scope while walking a tree, following
+// the scoping rules of the parser.
+type varScopes struct {
+	vars    []*varDecl // in scope, innermost last
+	all     []*varDecl
+	shadows []shadow
+	depth   int
+}
+
+func newVarScopes() *varScopes {
+	// $ is always defined, and need not be used.
+	return &varScopes{vars: []*varDecl{{node: &parse.VariableNode{Ident: []string{"$"}}, used: true}}}
+}
+
+// unused returns the declarations never used.
+func (s *varScopes) unused() []*varDecl {
+	var unused []*varDecl
+	for _, d := range s.all {
+		if !d.used && !d.key {
+			unused = append(unused, d)
+		}
+	}
+	return unused
+}
+
+func (s *varScopes) list(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, n := range list.Nodes {
+		s.node(n)
+	}
+}
+
+func (s *varScopes) node(node parse.Node) {
+	switch node := node.(type) {
+	case *parse.ActionNode:
+		s.uses(node.Pipe)
+		s.declare(node.Pipe.Decl, node)
+	case *parse.IfNode:
+		s.control(node.BranchNode)
+	case *parse.WithNode:
+		s.control(node.BranchNode)
+	case *parse.RangeNode:
+		s.control(node.BranchNode)
+	case *parse.TemplateNode:
+		if node.Pipe != nil {
+			s.uses(node.Pipe)
+		}
+	}
+}
+
+// control walks an if, with or range, whose variables are in scope for both
+// of its lists.
+func (s *varScopes) control(node parse.BranchNode) {
+	mark := len(s.vars)
+	s.depth++
+	s.uses(node.Pipe)
+	s.declare(node.Pipe.Decl, nil)
+	if len(node.Pipe.Decl) == 2 && node.Type() == parse.NodeRange {
+		s.vars[len(s.vars)-2].key = true
+	}
+	s.list(node.List)
+	s.list(node.ElseList)
+	s.vars = s.vars[:mark]
+	s.depth--
+}
+
+func (s *varScopes) declare(decl []*parse.VariableNode, action *parse.ActionNode) {
+	for _, v := range decl {
+		d := &varDecl{node: v, action: action, depth: s.depth}
+		if outer := s.lookup(v.Ident[0]); outer != nil && outer.depth < s.depth {
+			s.shadows = append(s.shadows, shadow{v, outer})
+		}
+		s.vars = append(s.vars, d)
+		s.all = append(s.all, d)
+	}
+}
+
+func (s *varScopes) lookup(name string) *varDecl {
+	for i := len(s.vars) - 1; i >= 0; i-- {
+		if s.vars[i].node.Ident[0] == name {
+			return s.vars[i]
+		}
+	}
+	return nil
+}
+
+// uses marks the variables pipe refers to as used.
+func (s *varScopes) uses(pipe *parse.PipeNode) {
+	for _, cmd := range pipe.Cmds {
+		parse.Inspect(cmd, func(n parse.Node) bool {
+			if v, ok := n.(*parse.VariableNode); ok {
+				if d := s.lookup(v.Ident[0]); d != nil {
+					d.used = true
+				}
+			}
+			return true
+		})
+	}
+}
+
+// line returns the line number of node in t.
+func line(t *parse.Tree, node parse.Node) int {
+	loc, _ := t.ErrorContext(node)
+	parts := strings.Split(loc, ":")
+	if len(parts) < 3 {
+		return 0
+	}
+	n, _ := strconv.Atoi(parts[len(parts)-2])
+	return n
+}
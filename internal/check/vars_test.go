@@ -0,0 +1,92 @@
+package check
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+func TestVars(t *testing.T) {
+	tpl := `{{$a := .A}}{{$b := .B}}{{$c := .C}}
+{{range $i, $v := .List}}{{$a := $v}}{{$a}}{{end}}
+{{with $d := .D}}{{$c}}{{else}}{{$d}}{{end}}
+{{range $k, $e := .M}}{{$k}}{{end}}
+{{define "y"}}{{$b := .}}{{if .}}{{$b := 1}}{{$b}}{{end}}{{end}}`
+	trees, err := parse.ParseNoFuncs("x", tpl, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range Vars(trees) {
+		got = append(got, f.Msg)
+	}
+	expected := []string{
+		"variable $a declared and not used",
+		"variable $b declared and not used",
+		"declaration of $a shadows declaration at line 1",
+		"variable $e declared and not used",
+		"variable $b declared and not used",
+		"declaration of $b shadows declaration at line 5",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, got)
+	}
+}
+
+func TestRemoveUnusedVars(t *testing.T) {
+	tpl := `{{$a := "a"}}{{$b := $a}}{{$g := .}}
+{{$c := printf "%s" .C}}{{- $d := 1}}{{$e := .E}}{{$e}}
+{{$h := .H.Field}}{{$i := .Method}}{{$j := $e.K}}{{$k := (1)}}
+{{if $f := .F}}x{{end}}`
+	expected := `
+{{$c := printf "%s" .C}}{{- $d := 1}}{{$e := .E}}{{$e}}
+{{$h := .H.Field}}{{$i := .Method}}{{$j := $e.K}}{{$k := (1)}}
+{{if $f := .F}}x{{end}}`
+	s, n, err := RemoveUnusedVars("x", tpl, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, s)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 removed but got %d", n)
+	}
+	// Fields and methods are still reported, though they aren't removed.
+	trees, err := parse.ParseNoFuncs("x", s, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range Vars(trees) {
+		got = append(got, f.Msg)
+	}
+	want := []string{
+		"variable $c declared and not used",
+		"variable $d declared and not used",
+		"variable $h declared and not used",
+		"variable $i declared and not used",
+		"variable $j declared and not used",
+		"variable $k declared and not used",
+		"variable $f declared and not used",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected:\n%q\n\nbut got:\n%q", want, got)
+	}
+}
+
+func TestRemoveUnusedVarsDelims(t *testing.T) {
+	tpl := `[[$a := 1]][[- $b := 2]][[$c := 3]][[$c]] {{$d := 4}}`
+	expected := `[[- $b := 2]][[$c := 3]][[$c]] {{$d := 4}}`
+	s, n, err := RemoveUnusedVars("x", tpl, "[[", "]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Fatalf("expected:\n%q\n\nbut got:\n%q", expected, s)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 removed but got %d", n)
+	}
+}
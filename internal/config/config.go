@@ -0,0 +1,226 @@
+// Package config reads the .gtfmt.toml files that configure gtfmt for the
+// templates in a directory and the directories below it.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of config files.
+const FileName = ".gtfmt.toml"
+
+// DefaultInclude are the globs of the files formatted in a directory given
+// on the command line when no config sets include.
+var DefaultInclude = []string{"*.tmpl", "*.tpl", "*.gotmpl", "*.gohtml"}
+
+// Config is the configuration of the templates in a directory: the settings
+// of the nearest config file in it or above it, with those of the next one up
+// for the keys it doesn't set, and so on up to a file setting root = true.
+type Config struct {
+	LeftDelim  string   // the left action delimiter; "" for {{
+	RightDelim string   // the right action delimiter; "" for }}
+	Simplify   string   // simplifications to make, as for -simplify, or "all"
+	Rewrite    []string // rewrite rules applied when formatting, e.g. '.Foo -> .Bar'
+	Funcs      []string // functions templates may call, for vet
+	Presets    []string // function presets templates may call, for vet
+	Include    []Glob   // files to format in a directory given
+	Exclude    []Glob   // files never to format or vet
+}
+
+// Glob is a pattern matching file paths, relative to the directory of the
+// config file that set it. A pattern without a slash matches the base name
+// of a file at any depth, and ** matches any number of directories.
+type Glob struct {
+	Dir     string
+	Pattern string
+}
+
+// Match reports whether the glob matches file.
+func (g Glob) Match(file string) bool {
+	rel, err := filepath.Rel(g.Dir, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if !strings.Contains(g.Pattern, "/") {
+		ok, _ := path.Match(g.Pattern, path.Base(rel))
+		return ok
+	}
+	return matchParts(strings.Split(strings.TrimPrefix(g.Pattern, "/"), "/"), strings.Split(rel, "/"))
+}
+
+// matchParts matches the path elements of a file against those of a pattern.
+func matchParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// Included reports whether file is one of the files to format when walking a
+// directory.
+func (c *Config) Included(file string) bool {
+	include := c.Include
+	if include == nil {
+		for _, p := range DefaultInclude {
+			include = append(include, Glob{Pattern: p, Dir: filepath.Dir(file)})
+		}
+	}
+	for _, g := range include {
+		if g.Match(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// Excluded reports whether file is never to be formatted or vetted.
+func (c *Config) Excluded(file string) bool {
+	for _, g := range c.Exclude {
+		if g.Match(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// Loader loads the configs of directories, reading each config file once.
+type Loader struct {
+	dirs map[string]*Config
+}
+
+// For returns the config of the templates in the file's directory.
+func (l *Loader) For(file string) (*Config, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	return l.Dir(filepath.Dir(abs))
+}
+
+// Dir returns the config of the templates in dir.
+func (l *Loader) Dir(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := l.dirs[dir]; ok {
+		return c, nil
+	}
+	if l.dirs == nil {
+		l.dirs = map[string]*Config{}
+	}
+	fn := filepath.Join(dir, FileName)
+	b, err := ioutil.ReadFile(fn)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err == nil {
+		if values, err = parseTOML(fn, string(b)); err != nil {
+			return nil, err
+		}
+	}
+	c := &Config{}
+	if parent := filepath.Dir(dir); parent != dir && values["root"] != true {
+		p, err := l.Dir(parent)
+		if err != nil {
+			return nil, err
+		}
+		*c = *p
+	}
+	if err := c.set(fn, dir, values); err != nil {
+		return nil, err
+	}
+	l.dirs[dir] = c
+	return c, nil
+}
+
+// set sets the fields of c to the values read from the config file fn in dir.
+func (c *Config) set(fn, dir string, values map[string]interface{}) error {
+	for key, v := range values {
+		var err error
+		switch key {
+		case "root":
+			if _, ok := v.(bool); !ok {
+				err = errors.New("must be a boolean")
+			}
+		case "delims":
+			delims, ok := v.([]string)
+			if !ok || len(delims) != 2 || delims[0] == "" || delims[1] == "" {
+				err = errors.New("must be an array of the left and right delimiters")
+				break
+			}
+			c.LeftDelim, c.RightDelim = delims[0], delims[1]
+		case "simplify":
+			c.Simplify, err = stringValue(v)
+		case "rewrite":
+			c.Rewrite, err = stringsValue(v)
+		case "funcs":
+			c.Funcs, err = stringsValue(v)
+		case "presets":
+			c.Presets, err = stringsValue(v)
+		case "include":
+			c.Include, err = globsValue(dir, v)
+		case "exclude":
+			c.Exclude, err = globsValue(dir, v)
+		default:
+			err = errors.New("unknown key")
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", fn, key, err)
+		}
+	}
+	return nil
+}
+
+func stringValue(v interface{}) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", errors.New("must be a string")
+	}
+	return s, nil
+}
+
+func stringsValue(v interface{}) ([]string, error) {
+	s, ok := v.([]string)
+	if !ok {
+		return nil, errors.New("must be an array of strings")
+	}
+	return s, nil
+}
+
+func globsValue(dir string, v interface{}) ([]Glob, error) {
+	patterns, err := stringsValue(v)
+	if err != nil {
+		return nil, err
+	}
+	globs := []Glob{}
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q", p)
+		}
+		globs = append(globs, Glob{Dir: dir, Pattern: p})
+	}
+	return globs, nil
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	text := `# settings
+delims = ["[[", "]]"] # for the legacy templates
+simplify = 'if-with, parens'
+root = true
+rewrite = [
+	".Foo -> .Bar", # renamed
+	"a # b -> c",
+]
+empty = []
+`
+	values, err := parseTOML("x.toml", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"delims":   []string{"[[", "]]"},
+		"simplify": "if-with, parens",
+		"root":     true,
+		"rewrite":  []string{".Foo -> .Bar", "a # b -> c"},
+		"empty":    []string{},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected:\n%#v\nbut got:\n%#v", expected, values)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"delims", "x.toml:1: expected key = value"},
+		{"a b = 1", `x.toml:1: invalid key "a b"`},
+		{"a = 1", "x.toml:1: a: expected a string, boolean or array of strings"},
+		{"a = \"b", "x.toml:1: a: unterminated string"},
+		{"\na = [\"b\"", "x.toml:2: a: unterminated array"},
+		{"a = [\"b\" \"c\"]", "x.toml:1: a: expected , or ] in array"},
+		{"a = true\na = false", "x.toml:2: a set more than once"},
+	}
+	for _, test := range tests {
+		_, err := parseTOML("x.toml", test.text)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q: expected error %q but got %v", test.text, test.err, err)
+		}
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		match   bool
+	}{
+		{"*.tmpl", "/r/a.tmpl", true},
+		{"*.tmpl", "/r/x/y/a.tmpl", true},
+		{"*.tmpl", "/other/a.tmpl", false},
+		{"x/*.tmpl", "/r/x/a.tmpl", true},
+		{"x/*.tmpl", "/r/x/y/a.tmpl", false},
+		{"x/**", "/r/x/y/a.tmpl", true},
+		{"**/y/*.tmpl", "/r/x/y/a.tmpl", true},
+		{"**/y/*.tmpl", "/r/y/a.tmpl", true},
+		{"/a.tmpl", "/r/a.tmpl", true},
+	}
+	for _, test := range tests {
+		g := Glob{Dir: filepath.FromSlash("/r"), Pattern: test.pattern}
+		if match := g.Match(filepath.FromSlash(test.file)); match != test.match {
+			t.Errorf("%s matching %s: expected %v but got %v", test.pattern, test.file, test.match, match)
+		}
+	}
+}
+
+func TestLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		FileName:                 "root = true\nsimplify = \"all\"\nfuncs = [\"upper\"]\nexclude = [\"vendor/**\"]\n",
+		"legacy/" + FileName:     "delims = [\"[[\", \"]]\"]\nfuncs = []\n",
+		"legacy/new/" + FileName: "root = true\n",
+		"bad/" + FileName:        "color = \"red\"\n",
+	}
+	for name, text := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := &Loader{}
+	c, err := l.For(filepath.Join(dir, "legacy", "x", "a.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Config{
+		LeftDelim:  "[[",
+		RightDelim: "]]",
+		Simplify:   "all",
+		Funcs:      []string{},
+		Exclude:    []Glob{{Dir: dir, Pattern: "vendor/**"}},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected:\n%#v\nbut got:\n%#v", expected, c)
+	}
+	if !c.Excluded(filepath.Join(dir, "vendor", "a.tmpl")) || c.Excluded(filepath.Join(dir, "legacy", "a.tmpl")) {
+		t.Error("expected only files in vendor to be excluded")
+	}
+	if !c.Included(filepath.Join(dir, "a.gotmpl")) || c.Included(filepath.Join(dir, "a.html")) {
+		t.Error("expected only template files to be included by default")
+	}
+	if c, err = l.Dir(filepath.Join(dir, "legacy", "new")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, &Config{}) {
+		t.Errorf("expected an empty config below a root but got:\n%#v", c)
+	}
+	_, err = l.Dir(filepath.Join(dir, "bad"))
+	if err == nil || !strings.HasSuffix(err.Error(), ": color: unknown key") {
+		t.Errorf("expected an unknown key error but got %v", err)
+	}
+}
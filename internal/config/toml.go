@@ -0,0 +1,168 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML used by config files: comments, and
+// keys set to a string, a boolean or an array of strings, which may span
+// lines. It returns the values by key, each a string, bool or []string.
+func parseTOML(name, text string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+		lineNum := i + 1
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", name, lineNum)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if !isKey(key) {
+			return nil, fmt.Errorf("%s:%d: invalid key %q", name, lineNum, key)
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("%s:%d: %s set more than once", name, lineNum, key)
+		}
+		val := strings.TrimSpace(line[eq+1:])
+		// An array continues until its closing bracket.
+		for strings.HasPrefix(val, "[") && !closed(val) && i+1 < len(lines) {
+			i++
+			val += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+		v, err := parseValue(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %v", name, lineNum, key, err)
+		}
+		values[key] = v
+	}
+	return values, nil
+}
+
+// isKey reports whether s is a bare TOML key.
+func isKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && r != '-' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && !('0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// stripComment removes a comment from the end of line.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// closed reports whether the array at the start of s is closed, or can't be
+// parsed.
+func closed(s string) bool {
+	_, _, err := parseArray(s)
+	return err != errArrayEnd
+}
+
+// parseValue parses a string, boolean or array of strings.
+func parseValue(s string) (interface{}, error) {
+	switch {
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case strings.HasPrefix(s, "["):
+		arr, rest, err := parseArray(s)
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" {
+			return nil, fmt.Errorf("unexpected %q after array", rest)
+		}
+		return arr, nil
+	}
+	str, rest, err := parseString(s)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("unexpected %q after string", rest)
+	}
+	return str, nil
+}
+
+// errArrayEnd is returned for an array without a closing bracket.
+var errArrayEnd = errors.New("unterminated array")
+
+// parseArray parses the array of strings at the start of s, returning the
+// text after it.
+func parseArray(s string) ([]string, string, error) {
+	s = strings.TrimSpace(s[1:])
+	arr := []string{}
+	for {
+		if s == "" {
+			return nil, "", errArrayEnd
+		}
+		if strings.HasPrefix(s, "]") {
+			return arr, strings.TrimSpace(s[1:]), nil
+		}
+		str, rest, err := parseString(s)
+		if err != nil {
+			return nil, "", err
+		}
+		arr = append(arr, str)
+		s = strings.TrimSpace(rest)
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimSpace(s[1:])
+		} else if s == "" {
+			return nil, "", errArrayEnd
+		} else if !strings.HasPrefix(s, "]") {
+			return nil, "", errors.New("expected , or ] in array")
+		}
+	}
+}
+
+// parseString parses the basic ("...") or literal ('...') string at the start
+// of s, returning the text after it.
+func parseString(s string) (string, string, error) {
+	if strings.HasPrefix(s, "'") {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], strings.TrimSpace(s[end+2:]), nil
+	}
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", fmt.Errorf("expected a string, boolean or array of strings")
+	}
+	q, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", "", fmt.Errorf("unterminated string")
+	}
+	str, err := strconv.Unquote(q)
+	if err != nil {
+		return "", "", err
+	}
+	return str, strings.TrimSpace(s[len(q):]), nil
+}
@@ -0,0 +1,237 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode"
+
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// rename returns the edits that rename the template variable or template name
+// at the given position.
+func (s *server) rename(uri string, pos Position, newName string) (*WorkspaceEdit, error) {
+	text, err := s.text(uri)
+	if err != nil {
+		return nil, err
+	}
+	d := s.delims(uri)
+	trees, err := parse.ParseNoFuncs(docName(uri), text, d.left, d.right)
+	if err != nil {
+		return nil, err
+	}
+	off := offset(text, pos)
+	var edits []TextEdit
+	if refs := varRefsAt(trees, off); refs != nil {
+		if !isVariableName(newName) {
+			return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid variable name %q", newName)}
+		}
+		for _, ref := range refs {
+			edits = append(edits, TextEdit{Range: span(text, ref.pos, ref.pos+len(ref.name)), NewText: newName})
+		}
+	} else if refs, err := nameRefsAt(text, d, trees, off); err != nil {
+		return nil, err
+	} else if refs != nil {
+		if newName == "" {
+			return nil, &responseError{Code: codeInvalidParams, Message: "template name may not be empty"}
+		}
+		for _, ref := range refs {
+			edits = append(edits, TextEdit{Range: span(text, ref.pos, ref.end), NewText: quote(newName, text[ref.pos])})
+		}
+	} else {
+		return nil, &responseError{Code: codeRequestFailed, Message: "no template variable or template name at position"}
+	}
+	return &WorkspaceEdit{Changes: map[string][]TextEdit{uri: edits}}, nil
+}
+
+// isVariableName reports whether name is a valid template variable other than $.
+func isVariableName(name string) bool {
+	if len(name) < 2 || name[0] != '$' {
+		return false
+	}
+	for _, r := range name[1:] {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// quote quotes a template name using the same kind of quote as the original.
+func quote(name string, orig byte) string {
+	if orig == '`' && strconv.CanBackquote(name) {
+		return "`" + name + "`"
+	}
+	return strconv.Quote(name)
+}
+
+// varRef is a declaration or use of a template variable.
+type varRef struct {
+	pos  int
+	name string              // the variable name, including the $
+	decl *parse.VariableNode // the declaration referred to; nil for $
+}
+
+// varRefsAt returns every reference to the variable at off, or nil if there is
+// no renamable variable at off.
+func varRefsAt(trees map[string]*parse.Tree, off int) []varRef {
+	for _, tree := range trees {
+		r := &resolver{}
+		r.walk(tree.Root)
+		for _, ref := range r.refs {
+			if ref.decl == nil || off < ref.pos || off > ref.pos+len(ref.name) {
+				continue
+			}
+			var refs []varRef
+			for _, other := range r.refs {
+				if other.decl == ref.decl {
+					refs = append(refs, other)
+				}
+			}
+			return refs
+		}
+	}
+	return nil
+}
+
+// resolver matches each use of a variable with its declaration, following
+// the same scoping rules as the parser.
+type resolver struct {
+	scope []*parse.VariableNode // declarations in scope, innermost last
+	refs  []varRef
+}
+
+func (r *resolver) walk(node parse.Node) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Nodes {
+			r.walk(n)
+		}
+	case *parse.ActionNode:
+		// Variables declared in an action persist until the enclosing end.
+		r.pipe(node.Pipe)
+	case *parse.IfNode:
+		r.branch(node.BranchNode)
+	case *parse.RangeNode:
+		r.branch(node.BranchNode)
+	case *parse.WithNode:
+		r.branch(node.BranchNode)
+	case *parse.TemplateNode:
+		r.pipe(node.Pipe)
+	case *parse.PipeNode:
+		r.pipe(node)
+	case *parse.CommandNode:
+		for _, n := range node.Args {
+			r.walk(n)
+		}
+	case *parse.ChainNode:
+		r.walk(node.Node)
+	case *parse.VariableNode:
+		r.use(node)
+	}
+}
+
+func (r *resolver) pipe(pipe *parse.PipeNode) {
+	if pipe == nil {
+		return
+	}
+	for _, v := range pipe.Decl {
+		r.scope = append(r.scope, v)
+		r.refs = append(r.refs, varRef{pos: int(v.Pos), name: v.Ident[0], decl: v})
+	}
+	for _, c := range pipe.Cmds {
+		r.walk(c)
+	}
+}
+
+func (r *resolver) branch(node parse.BranchNode) {
+	n := len(r.scope)
+	r.pipe(node.Pipe)
+	r.walk(node.List)
+	r.walk(node.ElseList)
+	r.scope = r.scope[:n]
+}
+
+func (r *resolver) use(v *parse.VariableNode) {
+	ref := varRef{pos: int(v.Pos), name: v.Ident[0]}
+	for i := len(r.scope) - 1; i >= 0; i-- {
+		if r.scope[i].Ident[0] == ref.name {
+			ref.decl = r.scope[i]
+			break
+		}
+	}
+	r.refs = append(r.refs, ref)
+}
+
+// nameRef is the quoted name in a define, block or template action, or in a
+// call of Helm's include function.
+type nameRef struct {
+	pos, end int
+	name     string
+}
+
+// nameRefsAt returns every reference to the template name at off, or nil if
+// there is no template name at off.
+func nameRefsAt(text string, d delims, trees map[string]*parse.Tree, off int) ([]nameRef, error) {
+	refs, err := nameRefs(text, d, trees)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if off < ref.pos || off > ref.end {
+			continue
+		}
+		var same []nameRef
+		for _, other := range refs {
+			if other.name == ref.name {
+				same = append(same, other)
+			}
+		}
+		return same, nil
+	}
+	return nil, nil
+}
+
+// nameRefs returns the template names used in text, sorted by position.
+func nameRefs(text string, d delims, trees map[string]*parse.Tree) ([]nameRef, error) {
+	seen := map[int]bool{}
+	var refs []nameRef
+	add := func(ref nameRef) {
+		if !seen[ref.pos] {
+			seen[ref.pos] = true
+			refs = append(refs, ref)
+		}
+	}
+	defs, err := parse.Definitions("", text, d.left, d.right)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range defs {
+		if d.NameEnd > d.NamePos {
+			add(nameRef{pos: int(d.NamePos), end: int(d.NameEnd), name: d.Name})
+		}
+	}
+	for _, tree := range trees {
+		parse.Inspect(tree.Root, func(n parse.Node) bool {
+			switch n := n.(type) {
+			case *parse.TemplateNode:
+				pos := int(n.Pos)
+				if q, err := strconv.QuotedPrefix(text[pos:]); err == nil {
+					add(nameRef{pos: pos, end: pos + len(q), name: n.Name})
+				}
+			case *parse.CommandNode:
+				// Helm's include function calls a template by name.
+				if fn, name, ok := parse.TemplateCall(n); ok && fn == "include" {
+					add(nameRef{pos: int(name.Pos), end: int(name.Pos) + len(name.Quoted), name: name.Text})
+				}
+			}
+			return true
+		})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].pos < refs[j].pos })
+	return refs, nil
+}
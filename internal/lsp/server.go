@@ -0,0 +1,325 @@
+// Package lsp implements a Language Server Protocol server for go templates,
+// offering the same formatting as the gtfmt command along with diagnostics,
+// renaming and document symbols.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gotpl/gtfmt/gtfmt"
+	"github.com/gotpl/gtfmt/internal/config"
+	"github.com/gotpl/gtfmt/internal/parse"
+)
+
+// Serve runs a language server that reads JSON-RPC messages from r and writes
+// responses and notifications to w. It returns when the client sends the exit
+// notification or r is closed.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{
+		in:   textproto.NewReader(bufio.NewReader(r)),
+		out:  w,
+		docs: map[string]string{},
+	}
+	return s.run()
+}
+
+type server struct {
+	in      *textproto.Reader
+	out     io.Writer
+	docs    map[string]string // document text by URI
+	configs config.Loader     // the configs of the documents' directories
+	exit    bool
+}
+
+// delims are the action delimiters of a document; empty for the defaults.
+type delims struct{ left, right string }
+
+// delims returns the delimiters set by the config of the directory of the
+// document with the given URI. Documents that aren't files, or whose config
+// can't be read, use the defaults.
+func (s *server) delims(uri string) delims {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return delims{}
+	}
+	cfg, err := s.configs.For(filepath.FromSlash(u.Path))
+	if err != nil {
+		return delims{}
+	}
+	return delims{cfg.LeftDelim, cfg.RightDelim}
+}
+
+func (s *server) run() error {
+	for !s.exit {
+		req, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result, err := s.handle(req)
+		if req.ID == nil {
+			// notifications get no response.
+			continue
+		}
+		resp := &response{JSONRPC: "2.0", ID: req.ID}
+		if err != nil {
+			rerr, ok := err.(*responseError)
+			if !ok {
+				rerr = &responseError{Code: codeRequestFailed, Message: err.Error()}
+			}
+			resp.Error = rerr
+		} else if resp.Result, err = json.Marshal(result); err != nil {
+			return err
+		}
+		if err := s.write(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read reads a single message, framed by a Content-Length header.
+func (s *server) read() (*request, error) {
+	header, err := s.in.ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %v", err)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(s.in.R, body); err != nil {
+		return nil, err
+	}
+	req := &request{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// write writes a single message, framed by a Content-Length header.
+func (s *server) write(msg interface{}) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	return err
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	return s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *server) handle(req *request) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":                1, // full document sync
+				"documentFormattingProvider":      true,
+				"documentRangeFormattingProvider": true,
+				"renameProvider":                  true,
+				"documentSymbolProvider":          true,
+			},
+			"serverInfo": map[string]string{"name": "gtfmt"},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "exit":
+		s.exit = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/formatting":
+		var params formattingParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.format(params.TextDocument.URI, nil)
+	case "textDocument/rangeFormatting":
+		var params rangeFormattingParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.format(params.TextDocument.URI, &params.Range)
+	case "textDocument/rename":
+		var params renameParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.rename(params.TextDocument.URI, params.Position, params.NewName)
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.symbols(params.TextDocument.URI)
+	}
+	if req.ID == nil || strings.HasPrefix(req.Method, "$/") {
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func unmarshal(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// text returns the contents of the open document with the given URI.
+func (s *server) text(uri string) (string, error) {
+	text, ok := s.docs[uri]
+	if !ok {
+		return "", &responseError{Code: codeInvalidParams, Message: "unknown document: " + uri}
+	}
+	return text, nil
+}
+
+// update records the new text of a document and publishes its diagnostics.
+func (s *server) update(uri, text string) error {
+	s.docs[uri] = text
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnose(docName(uri), text, s.delims(uri)),
+	})
+}
+
+// diagnose returns the parse errors in text.
+func diagnose(name, text string, d delims) []Diagnostic {
+	diags := []Diagnostic{}
+	f, err := gtfmt.NewFormatter(gtfmt.Options{LeftDelim: d.left, RightDelim: d.right, KeepSpace: true, AllErrors: true})
+	if err == nil {
+		_, err = f.Edits(gtfmt.Source{Filename: name, Text: []byte(text)})
+	}
+	if err == nil {
+		return diags
+	}
+	errs, ok := err.(gtfmt.ErrorList)
+	if !ok {
+		return append(diags, Diagnostic{Severity: severityError, Source: "gtfmt", Message: err.Error()})
+	}
+	for _, e := range errs {
+		diags = append(diags, Diagnostic{
+			Range:    span(text, e.Offset, runeEnd(text, e.Offset)),
+			Severity: severityError,
+			Source:   "gtfmt",
+			Message:  e.Msg,
+		})
+	}
+	return diags
+}
+
+// runeEnd returns the offset just past the rune at off, or off itself at the
+// end of a line or of the text.
+func runeEnd(text string, off int) int {
+	if off >= len(text) || text[off] == '\n' {
+		return off
+	}
+	_, w := utf8.DecodeRuneInString(text[off:])
+	return off + w
+}
+
+// format formats the document, or only the actions within r if it is not nil.
+func (s *server) format(uri string, r *Range) ([]TextEdit, error) {
+	text, err := s.text(uri)
+	if err != nil {
+		return nil, err
+	}
+	d := s.delims(uri)
+	var changes []gtfmt.TextEdit
+	switch {
+	case d != delims{}:
+		// Templates with custom delimiters are formatted in place.
+		changes, err = formatterEdits(gtfmt.Options{LeftDelim: d.left, RightDelim: d.right}, docName(uri), text, r)
+	case r == nil:
+		changes, err = gtfmt.Edits(docName(uri), text)
+	default:
+		changes, err = gtfmt.RangeEdits(docName(uri), text, offset(text, r.Start), offset(text, r.End))
+	}
+	if parseFailed(err) {
+		// Still format templates being edited, whose blocks may not balance.
+		opts := gtfmt.Options{LeftDelim: d.left, RightDelim: d.right, Partial: true}
+		changes, err = formatterEdits(opts, docName(uri), text, r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	edits := []TextEdit{}
+	for _, e := range changes {
+		edits = append(edits, TextEdit{Range: span(text, e.Start, e.End), NewText: e.NewText})
+	}
+	return edits, nil
+}
+
+// parseFailed reports whether err is from a template that doesn't parse, as
+// opposed to one gtfmt refuses to format.
+func parseFailed(err error) bool {
+	switch err.(type) {
+	case *parse.Error, *gtfmt.Error, gtfmt.ErrorList, gtfmt.BlockErrors:
+		return true
+	}
+	return false
+}
+
+// formatterEdits returns the edits that a Formatter with opts makes to text,
+// limited to those starting within r if it's not nil.
+func formatterEdits(opts gtfmt.Options, name, text string, r *Range) ([]gtfmt.TextEdit, error) {
+	f, err := gtfmt.NewFormatter(opts)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := f.Edits(gtfmt.Source{Filename: name, Text: []byte(text)})
+	if err != nil || r == nil {
+		return changes, err
+	}
+	start, end := offset(text, r.Start), offset(text, r.End)
+	var in []gtfmt.TextEdit
+	for _, e := range changes {
+		if e.Start >= start && e.Start < end {
+			in = append(in, e)
+		}
+	}
+	return in, nil
+}
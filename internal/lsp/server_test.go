@@ -0,0 +1,426 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// client is a minimal JSON-RPC client talking to a server over pipes.
+type client struct {
+	t      *testing.T
+	w      io.WriteCloser
+	r      *textproto.Reader
+	id     int
+	done   chan error
+	notifs []json.RawMessage // publishDiagnostics params received so far
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, r: textproto.NewReader(bufio.NewReader(outR)), done: make(chan error, 1)}
+	go func() {
+		err := Serve(inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *client) send(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(b), b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) recv() map[string]json.RawMessage {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		c.t.Fatal(err)
+	}
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// call sends a request and decodes its result into result, collecting any
+// notifications sent before the response.
+func (c *client) call(method string, params, result interface{}) *responseError {
+	c.id++
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+	for {
+		msg := c.recv()
+		if _, ok := msg["id"]; !ok {
+			c.notifs = append(c.notifs, msg["params"])
+			continue
+		}
+		if e, ok := msg["error"]; ok {
+			rerr := &responseError{}
+			if err := json.Unmarshal(e, rerr); err != nil {
+				c.t.Fatal(err)
+			}
+			return rerr
+		}
+		if err := json.Unmarshal(msg["result"], result); err != nil {
+			c.t.Fatal(err)
+		}
+		return nil
+	}
+}
+
+// diagnostics waits for the next publishDiagnostics notification.
+func (c *client) diagnostics() publishDiagnosticsParams {
+	var params publishDiagnosticsParams
+	var raw json.RawMessage
+	if len(c.notifs) > 0 {
+		raw, c.notifs = c.notifs[0], c.notifs[1:]
+	} else {
+		raw = c.recv()["params"]
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+func (c *client) close() {
+	var result interface{}
+	if err := c.call("shutdown", nil, &result); err != nil {
+		c.t.Fatal(err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Fatal(err)
+	}
+	c.w.Close()
+}
+
+func (c *client) open(uri, text string) {
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "gotmpl", "version": 1, "text": text},
+	})
+}
+
+func doc(uri string) map[string]interface{} {
+	return map[string]interface{}{"uri": uri}
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	var init map[string]interface{}
+	if err := c.call("initialize", map[string]interface{}{}, &init); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	const uri = "file:///tmp/a.tmpl"
+	c.open(uri, "{{  .A  }}\n{{  .B  }}\n")
+	if d := c.diagnostics(); len(d.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %v", d.Diagnostics)
+	}
+
+	var edits []TextEdit
+	if err := c.call("textDocument/formatting", map[string]interface{}{"textDocument": doc(uri)}, &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{
+		{Range: Range{End: Position{Character: 10}}, NewText: "{{.A}}"},
+		{Range: Range{Start: Position{Line: 1}, End: Position{Line: 1, Character: 10}}, NewText: "{{.B}}"},
+	}
+	if !reflect.DeepEqual(expected, edits) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, edits)
+	}
+
+	params := map[string]interface{}{
+		"textDocument": doc(uri),
+		"range":        Range{Start: Position{Line: 1}, End: Position{Line: 2}},
+	}
+	if err := c.call("textDocument/rangeFormatting", params, &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected = []TextEdit{{Range: Range{Start: Position{Line: 1}, End: Position{Line: 1, Character: 10}}, NewText: "{{.B}}"}}
+	if !reflect.DeepEqual(expected, edits) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, edits)
+	}
+}
+
+func TestFormattingDelims(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, ".gtfmt.toml"), []byte("root = true\ndelims = [\"[[\", \"]]\"]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	defer c.close()
+	uri := "file://" + filepath.ToSlash(filepath.Join(dir, "a.tmpl"))
+	c.open(uri, "[[  .A  ]] {{ x }}")
+	if d := c.diagnostics(); len(d.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %v", d.Diagnostics)
+	}
+
+	var edits []TextEdit
+	if err := c.call("textDocument/formatting", map[string]interface{}{"textDocument": doc(uri)}, &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{{Range: Range{End: Position{Character: 10}}, NewText: "[[.A]]"}}
+	if !reflect.DeepEqual(expected, edits) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, edits)
+	}
+}
+
+func TestFormattingPartial(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	const uri = "file:///tmp/a.tmpl"
+	c.open(uri, "{{  if .A  }}\n{{  .B  }}\n")
+	if d := c.diagnostics(); len(d.Diagnostics) != 1 {
+		t.Errorf("expected a diagnostic, got %v", d.Diagnostics)
+	}
+
+	var edits []TextEdit
+	if err := c.call("textDocument/formatting", map[string]interface{}{"textDocument": doc(uri)}, &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{
+		{Range: Range{End: Position{Character: 13}}, NewText: "{{if .A}}"},
+		{Range: Range{Start: Position{Line: 1}, End: Position{Line: 1, Character: 10}}, NewText: "{{.B}}"},
+	}
+	if !reflect.DeepEqual(expected, edits) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, edits)
+	}
+
+	params := map[string]interface{}{
+		"textDocument": doc(uri),
+		"range":        Range{Start: Position{Line: 1}, End: Position{Line: 2}},
+	}
+	if err := c.call("textDocument/rangeFormatting", params, &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected = expected[1:]
+	if !reflect.DeepEqual(expected, edits) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, edits)
+	}
+}
+
+func TestFormattingDefine(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	const uri = "file:///tmp/a.tmpl"
+	c.open(uri, "{{define \"a\"}}{{  .A  }}{{end}}\n")
+	c.diagnostics()
+
+	var edits []TextEdit
+	err := c.call("textDocument/formatting", map[string]interface{}{"textDocument": doc(uri)}, &edits)
+	if err == nil || !strings.Contains(err.Message, "sub templates not currently supported") {
+		t.Errorf("expected sub templates error but got %v, %v", err, edits)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	const uri = "file:///tmp/a.tmpl"
+	c.open(uri, "ok\n{{if .X}}\n  {{.Y ) }}\n{{end}}")
+	d := c.diagnostics()
+	expected := []Diagnostic{{
+		Range:    Range{Start: Position{Line: 2, Character: 7}, End: Position{Line: 2, Character: 8}},
+		Severity: severityError,
+		Source:   "gtfmt",
+		Message:  `unexpected ")" in input`,
+	}}
+	if !reflect.DeepEqual(expected, d.Diagnostics) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, d.Diagnostics)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   doc(uri),
+		"contentChanges": []map[string]string{{"text": "{{.X}}"}},
+	})
+	if d := c.diagnostics(); len(d.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %v", d.Diagnostics)
+	}
+}
+
+func TestDiagnosticsAllErrors(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	const uri = "file:///tmp/a.tmpl"
+	c.open(uri, "{{.A ) }}\n{{if}}\n")
+	d := c.diagnostics()
+	expected := []Diagnostic{
+		{
+			Range:    Range{Start: Position{Character: 5}, End: Position{Character: 6}},
+			Severity: severityError,
+			Source:   "gtfmt",
+			Message:  `unexpected ")" in input`,
+		},
+		{
+			Range:    Range{Start: Position{Line: 1, Character: 4}, End: Position{Line: 1, Character: 5}},
+			Severity: severityError,
+			Source:   "gtfmt",
+			Message:  "missing value for if",
+		},
+	}
+	if !reflect.DeepEqual(expected, d.Diagnostics) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, d.Diagnostics)
+	}
+}
+
+func TestRenameVariable(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	const uri = "file:///tmp/a.tmpl"
+	c.open(uri, "{{$x := 1}}{{range $x := .}}{{$x}}{{end}}{{$x.Y}}")
+	c.diagnostics()
+
+	params := map[string]interface{}{
+		"textDocument": doc(uri),
+		"position":     Position{Character: 3},
+		"newName":      "$y",
+	}
+	var edit WorkspaceEdit
+	if err := c.call("textDocument/rename", params, &edit); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{
+		{Range: Range{Start: Position{Character: 2}, End: Position{Character: 4}}, NewText: "$y"},
+		{Range: Range{Start: Position{Character: 43}, End: Position{Character: 45}}, NewText: "$y"},
+	}
+	if !reflect.DeepEqual(expected, edit.Changes[uri]) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, edit.Changes[uri])
+	}
+
+	params["newName"] = "y"
+	if err := c.call("textDocument/rename", params, &edit); err == nil {
+		t.Error("expected error renaming to an invalid variable name")
+	}
+}
+
+func TestRenameTemplate(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	const uri = "file:///tmp/a.tmpl"
+	c.open(uri, "{{define `a`}}A{{end}}\n{{template \"a\" .}}{{block \"b\" .}}{{template `a`}}{{end}}")
+	c.diagnostics()
+
+	params := map[string]interface{}{
+		"textDocument": doc(uri),
+		"position":     Position{Line: 1, Character: 12},
+		"newName":      "c",
+	}
+	var edit WorkspaceEdit
+	if err := c.call("textDocument/rename", params, &edit); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{
+		{Range: Range{Start: Position{Character: 9}, End: Position{Character: 12}}, NewText: "`c`"},
+		{Range: Range{Start: Position{Line: 1, Character: 11}, End: Position{Line: 1, Character: 14}}, NewText: `"c"`},
+		{Range: Range{Start: Position{Line: 1, Character: 44}, End: Position{Line: 1, Character: 47}}, NewText: "`c`"},
+	}
+	if !reflect.DeepEqual(expected, edit.Changes[uri]) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, edit.Changes[uri])
+	}
+}
+
+func TestRenameInclude(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	const uri = "file:///tmp/_helpers.tpl"
+	c.open(uri, "{{define \"app.name\"}}a{{end}}\n{{include \"app.name\" . | quote}} {{include \"other\" .}}")
+	c.diagnostics()
+
+	params := map[string]interface{}{
+		"textDocument": doc(uri),
+		"position":     Position{Line: 1, Character: 12},
+		"newName":      "app.fullname",
+	}
+	var edit WorkspaceEdit
+	if err := c.call("textDocument/rename", params, &edit); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{
+		{Range: Range{Start: Position{Character: 9}, End: Position{Character: 19}}, NewText: `"app.fullname"`},
+		{Range: Range{Start: Position{Line: 1, Character: 10}, End: Position{Line: 1, Character: 20}}, NewText: `"app.fullname"`},
+	}
+	if !reflect.DeepEqual(expected, edit.Changes[uri]) {
+		t.Errorf("expected:\n%v\n\ngot:\n%v", expected, edit.Changes[uri])
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	const uri = "file:///tmp/a.tmpl"
+	c.open(uri, "{{define \"a\"}}{{if .}}{{block \"b\" .}}{{end}}{{end}}{{end}}\n{{define \"c\"}}{{end}}")
+	c.diagnostics()
+
+	var syms []DocumentSymbol
+	if err := c.call("textDocument/documentSymbol", map[string]interface{}{"textDocument": doc(uri)}, &syms); err != nil {
+		t.Fatal(err)
+	}
+	expected := []DocumentSymbol{
+		{
+			Name: "a", Detail: "define", Kind: symbolFunction,
+			Range:          Range{End: Position{Character: 58}},
+			SelectionRange: Range{Start: Position{Character: 9}, End: Position{Character: 12}},
+			Children: []DocumentSymbol{{
+				Name: "b", Detail: "block", Kind: symbolFunction,
+				Range:          Range{Start: Position{Character: 22}, End: Position{Character: 44}},
+				SelectionRange: Range{Start: Position{Character: 30}, End: Position{Character: 33}},
+			}},
+		},
+		{
+			Name: "c", Detail: "define", Kind: symbolFunction,
+			Range:          Range{Start: Position{Line: 1}, End: Position{Line: 1, Character: 21}},
+			SelectionRange: Range{Start: Position{Line: 1, Character: 9}, End: Position{Line: 1, Character: 12}},
+		},
+	}
+	if !reflect.DeepEqual(expected, syms) {
+		t.Errorf("expected:\n%+v\n\ngot:\n%+v", expected, syms)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	text := "a\né\U0001F600x\n"
+	off := 1 + 1 + 2 + 4
+	p := position(text, off)
+	if expected := (Position{Line: 1, Character: 3}); p != expected {
+		t.Errorf("expected %v, got %v", expected, p)
+	}
+	if o := offset(text, p); o != off {
+		t.Errorf("expected offset %d, got %d", off, o)
+	}
+}
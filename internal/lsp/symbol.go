@@ -0,0 +1,42 @@
+package lsp
+
+import "github.com/gotpl/gtfmt/internal/parse"
+
+// symbols returns a symbol for each {{define}} and {{block}} in the document.
+func (s *server) symbols(uri string) ([]DocumentSymbol, error) {
+	text, err := s.text(uri)
+	if err != nil {
+		return nil, err
+	}
+	// Report whatever definitions could be found in a document that doesn't lex.
+	d := s.delims(uri)
+	defs, _ := parse.Definitions(docName(uri), text, d.left, d.right)
+	syms, _ := nest(text, defs, len(text)+1)
+	if syms == nil {
+		syms = []DocumentSymbol{}
+	}
+	return syms, nil
+}
+
+// nest converts the definitions starting before end into symbols, nesting
+// definitions inside those that contain them. It returns the remaining
+// definitions.
+func nest(text string, defs []*parse.Definition, end int) ([]DocumentSymbol, []*parse.Definition) {
+	var syms []DocumentSymbol
+	for len(defs) > 0 && int(defs[0].Pos) < end {
+		d := defs[0]
+		sym := DocumentSymbol{
+			Name:           d.Name,
+			Detail:         "define",
+			Kind:           symbolFunction,
+			Range:          span(text, int(d.Pos), int(d.End)),
+			SelectionRange: span(text, int(d.NamePos), int(d.NameEnd)),
+		}
+		if d.Block {
+			sym.Detail = "block"
+		}
+		sym.Children, defs = nest(text, defs[1:], int(d.End))
+		syms = append(syms, sym)
+	}
+	return syms, defs
+}
@@ -0,0 +1,62 @@
+package lsp
+
+import (
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// offset converts an LSP position to a byte offset in text, clamping positions
+// past the end of a line or of the text.
+func offset(text string, p Position) int {
+	off := 0
+	for line := 0; line < p.Line; line++ {
+		nl := strings.IndexByte(text[off:], '\n')
+		if nl < 0 {
+			return len(text)
+		}
+		off += nl + 1
+	}
+	for col := 0; col < p.Character && off < len(text); {
+		r, w := utf8.DecodeRuneInString(text[off:])
+		if r == '\n' {
+			break
+		}
+		col += utf16Len(r)
+		off += w
+	}
+	return off
+}
+
+// position converts a byte offset in text to an LSP position.
+func position(text string, off int) Position {
+	if off > len(text) {
+		off = len(text)
+	}
+	before := text[:off]
+	line := strings.Count(before, "\n")
+	start := strings.LastIndexByte(before, '\n') + 1
+	col := 0
+	for _, r := range before[start:] {
+		col += utf16Len(r)
+	}
+	return Position{Line: line, Character: col}
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// span returns the range covering the bytes [start, end) of text.
+func span(text string, start, end int) Range {
+	return Range{Start: position(text, start), End: position(text, end)}
+}
+
+// docName returns the name used for a document in error messages.
+func docName(uri string) string {
+	return path.Base(uri)
+}
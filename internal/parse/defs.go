@@ -0,0 +1,85 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Definition is the location of a {{define}} or {{block}} in a text.
+type Definition struct {
+	Name    string
+	Block   bool // if true, defined by {{block}}
+	NamePos Pos  // start of the quoted name
+	NameEnd Pos  // end of the quoted name; NamePos if the name is missing
+	Pos     Pos  // start of the opening action
+	End     Pos  // end of the matching end action
+}
+
+// Definitions returns the definitions in text in lexical order. If text
+// cannot be lexed, the definitions found before the error are returned along
+// with it; unterminated definitions extend to the end of the text.
+func Definitions(name, text, leftDelim, rightDelim string) ([]*Definition, error) {
+	actions, err := Actions(name, text, leftDelim, rightDelim)
+	var defs []*Definition
+	var open []*Definition // enclosing blocks; nil for if, range and with
+	for _, a := range actions {
+		body := a.Body(text)
+		trimmed := strings.TrimLeft(body, " \t")
+		word := trimmed[:len(trimmed)-len(strings.TrimLeftFunc(trimmed, unicode.IsLetter))]
+		switch word {
+		case "if", "range", "with":
+			open = append(open, nil)
+		case "define", "block":
+			d := &Definition{
+				Block:   word == "block",
+				NamePos: a.Pos,
+				NameEnd: a.Pos,
+				Pos:     a.Pos,
+				End:     Pos(len(text)),
+			}
+			rest := strings.TrimLeft(trimmed[len(word):], " \t")
+			if q, err := strconv.QuotedPrefix(rest); err == nil {
+				d.Name, _ = strconv.Unquote(q)
+				d.NamePos = a.Pos + Pos(len(a.Left)+len(body)-len(rest))
+				d.NameEnd = d.NamePos + Pos(len(q))
+			}
+			defs = append(defs, d)
+			open = append(open, d)
+		case "end":
+			if len(open) == 0 {
+				continue
+			}
+			if d := open[len(open)-1]; d != nil {
+				d.End = a.End
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	return defs, err
+}
+
+// TemplateCall returns the name of the function cmd calls, such as "include"
+// or, for a function in a namespace, "partials.Include", along with the string
+// constant passed as its first argument, as to functions that execute the
+// template named by it. ok is false if cmd isn't a call of a function with a
+// string constant as its first argument.
+func TemplateCall(cmd *CommandNode) (fn string, name *StringNode, ok bool) {
+	if len(cmd.Args) < 2 {
+		return "", nil, false
+	}
+	switch n := cmd.Args[0].(type) {
+	case *IdentifierNode:
+		fn = n.Ident
+	case *ChainNode:
+		id, ok := n.Node.(*IdentifierNode)
+		if !ok {
+			return "", nil, false
+		}
+		fn = id.Ident + "." + strings.Join(n.Field, ".")
+	default:
+		return "", nil, false
+	}
+	name, ok = cmd.Args[1].(*StringNode)
+	return fn, name, ok
+}
@@ -0,0 +1,26 @@
+package parse
+
+import (
+	"testing"
+)
+
+func TestDefinitions(t *testing.T) {
+	text := "{{define \"a\"}}{{if .}}{{block `b` .}}x{{end}}{{end}}{{end}}{{define \"c\"}}"
+	defs, err := Definitions("test", text, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Definition{
+		{Name: "a", NamePos: 9, NameEnd: 12, Pos: 0, End: 59},
+		{Name: "b", Block: true, NamePos: 30, NameEnd: 33, Pos: 22, End: 45},
+		{Name: "c", NamePos: 68, NameEnd: 71, Pos: 59, End: 73},
+	}
+	if len(defs) != len(expected) {
+		t.Fatalf("expected %d definitions, got %d: %v", len(expected), len(defs), defs)
+	}
+	for i, d := range defs {
+		if *d != expected[i] {
+			t.Errorf("definition %d: expected %+v, got %+v", i, expected[i], *d)
+		}
+	}
+}
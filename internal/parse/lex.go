@@ -118,6 +118,7 @@ type lexer struct {
 	items      chan item // channel of scanned items
 	parenDepth int       // nesting depth of ( ) exprs
 	line       int       // 1+number of newlines seen
+	resync     bool      // if true, carry on from the next action after an error
 }
 
 // next returns the next rune in the input.
@@ -185,9 +186,27 @@ func (l *lexer) acceptRun(valid string) {
 
 // errorf returns an error token and terminates the scan by passing
 // back a nil pointer that will be the next state, terminating l.nextItem.
+// If resyncing, the scan instead carries on from the next left delimiter.
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
 	l.items <- item{itemError, l.start, fmt.Sprintf(format, args...), l.line}
-	return nil
+	if !l.resync {
+		return nil
+	}
+	// The error may be at the delimiter of a comment.
+	next := Pos(len(l.input))
+	if int(l.start) < len(l.input) {
+		if x := strings.Index(l.input[l.start+1:], l.leftDelim); x >= 0 {
+			next = l.start + 1 + Pos(x)
+		}
+	}
+	if next > l.pos {
+		l.line += strings.Count(l.input[l.pos:next], "\n")
+	} else {
+		l.line -= strings.Count(l.input[next:l.pos], "\n")
+	}
+	l.pos, l.start = next, next
+	l.parenDepth = 0
+	return lexText
 }
 
 // nextItem returns the next item from the input.
@@ -207,6 +226,13 @@ func (l *lexer) drain() {
 
 // lex creates a new scanner for the input string.
 func lex(name, input, left, right string) *lexer {
+	l := newLexer(name, input, left, right)
+	go l.run()
+	return l
+}
+
+// newLexer returns a scanner for the input string that has yet to be run.
+func newLexer(name, input, left, right string) *lexer {
 	if left == "" {
 		left = leftDelim
 	}
@@ -221,10 +247,138 @@ func lex(name, input, left, right string) *lexer {
 		items:      make(chan item),
 		line:       1,
 	}
-	go l.run()
 	return l
 }
 
+// Action describes where a single delimited action appears in the input text.
+// Comments are not actions.
+type Action struct {
+	Pos   Pos    // position of the left delimiter
+	End   Pos    // position just past the right delimiter
+	Left  string // the left delimiter, including its trim marker if any
+	Right string // the right delimiter, including its trim marker if any
+}
+
+// Body returns the text of the action between its delimiters and trim markers.
+func (a Action) Body(text string) string {
+	return text[int(a.Pos)+len(a.Left) : int(a.End)-len(a.Right)]
+}
+
+// Actions lexes text and returns the location of every action in it, in
+// lexical order. If either action delimiter string is empty, the default
+// ("{{" or "}}") is used. If lexing fails, the actions found before the error
+// are returned along with it.
+func Actions(name, text, leftDelim, rightDelim string) ([]Action, error) {
+	l := lex(name, text, leftDelim, rightDelim)
+	var actions []Action
+	var a Action
+	for {
+		item := l.nextItem()
+		switch item.typ {
+		case itemEOF:
+			return actions, nil
+		case itemError:
+			l.drain()
+			return actions, &Error{Name: name, Line: item.line, Pos: item.pos, Msg: item.val}
+		case itemLeftDelim:
+			a = Action{Pos: item.pos, Left: l.leftDelim}
+			if strings.HasPrefix(text[int(item.pos)+len(l.leftDelim):], leftTrimMarker) {
+				a.Left += leftTrimMarker
+			}
+		case itemRightDelim:
+			a.End = item.pos + Pos(len(l.rightDelim))
+			a.Right = l.rightDelim
+			if strings.HasSuffix(text[:item.pos], rightTrimMarker) {
+				a.Right = rightTrimMarker + a.Right
+			}
+			actions = append(actions, a)
+		}
+	}
+}
+
+// TokenAction is an action along with its body printed from its tokens.
+type TokenAction struct {
+	Action
+	Printed string // the body printed from its tokens
+}
+
+// TokenActions lexes text like Actions, returning each action along with its
+// body printed from its tokens alone, as it prints in a parsed tree: with a
+// single space between arguments, around pipes and declarations and after
+// commas, and none inside parentheses. The text need not parse, as
+// the block structure of the actions is ignored. Actions that fail to lex are
+// left out, and lexing carries on from the next left delimiter.
+func TokenActions(name, text, leftDelim, rightDelim string) []TokenAction {
+	l := newLexer(name, text, leftDelim, rightDelim)
+	l.resync = true
+	go l.run()
+	var actions []TokenAction
+	var a TokenAction
+	var body strings.Builder
+	var prev item
+	in, space := false, false
+	for {
+		item := l.nextItem()
+		switch item.typ {
+		case itemEOF:
+			return actions
+		case itemError:
+			in = false
+		case itemLeftDelim:
+			a = TokenAction{Action: Action{Pos: item.pos, Left: l.leftDelim}}
+			if strings.HasPrefix(text[int(item.pos)+len(l.leftDelim):], leftTrimMarker) {
+				a.Left += leftTrimMarker
+			}
+			body.Reset()
+			in, space = true, false
+		case itemRightDelim:
+			if !in {
+				continue
+			}
+			a.End = item.pos + Pos(len(l.rightDelim))
+			a.Right = l.rightDelim
+			if strings.HasSuffix(text[:item.pos], rightTrimMarker) {
+				a.Right = rightTrimMarker + a.Right
+				if body.Len() > 0 && prev.pos+Pos(len(prev.val)) == item.pos {
+					// The trim marker lexed as a token.
+					in = false
+					continue
+				}
+			}
+			a.Printed = body.String()
+			actions = append(actions, a)
+			in = false
+		case itemSpace:
+			space = true
+		case itemText:
+		default:
+			if !in {
+				continue
+			}
+			if body.Len() > 0 {
+				body.WriteString(tokenSep(prev, item, space))
+			}
+			body.WriteString(item.val)
+			prev, space = item, false
+		}
+	}
+}
+
+// tokenSep returns the separator printed between the tokens a and b of an
+// action, given whether there was space between them.
+func tokenSep(a, b item, space bool) string {
+	comma := func(i item) bool { return i.typ == itemChar && i.val == "," }
+	switch {
+	case a.typ == itemLeftParen, b.typ == itemRightParen, comma(b):
+		return ""
+	case a.typ == itemPipe, b.typ == itemPipe, a.typ == itemColonEquals, b.typ == itemColonEquals, comma(a):
+		return " "
+	case space:
+		return " "
+	}
+	return ""
+}
+
 // run runs the state machine for the lexer.
 func (l *lexer) run() {
 	for l.state = lexText; l.state != nil; {
@@ -303,8 +457,8 @@ func lexLeftDelim(l *lexer) stateFn {
 		afterMarker = trimMarkerLen
 	}
 	if strings.HasPrefix(l.input[l.pos+afterMarker:], leftComment) {
+		// Errors in the comment are reported at the delimiter.
 		l.pos += afterMarker
-		l.ignore()
 		return lexComment
 	}
 	l.emit(itemLeftDelim)
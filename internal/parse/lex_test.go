@@ -520,3 +520,47 @@ func (t *Tree) parseLexer(lex *lexer) (tree *Tree, err error) {
 	t.stopParse()
 	return t, nil
 }
+
+func TestActions(t *testing.T) {
+	text := "a{{.X}}b{{- /* c */ -}}\n{{- if .Y -}}c{{end}}"
+	actions, err := Actions("test", text, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Action{
+		{Pos: 1, End: 7, Left: "{{", Right: "}}"},
+		{Pos: 24, End: 37, Left: "{{- ", Right: " -}}"},
+		{Pos: 38, End: 45, Left: "{{", Right: "}}"},
+	}
+	if len(actions) != len(expected) {
+		t.Fatalf("expected %d actions, got %d: %v", len(expected), len(actions), actions)
+	}
+	for i, a := range actions {
+		if a != expected[i] {
+			t.Errorf("action %d: expected %+v, got %+v", i, expected[i], a)
+		}
+	}
+	if body := actions[1].Body(text); body != "if .Y" {
+		t.Errorf("expected body %q, got %q", "if .Y", body)
+	}
+	if _, err := Actions("test", "{{.X", "", ""); err == nil {
+		t.Error("expected error for unclosed action")
+	}
+}
+
+func TestTokenActions(t *testing.T) {
+	text := "{{  if  .A|f  ( len  .B ) }}{{ \"x }}{{range  $i ,$x:=.L -}}{{  .X  -}}"
+	actions := TokenActions("test", text, "", "")
+	expected := []TokenAction{
+		{Action{Pos: 0, End: 28, Left: "{{", Right: "}}"}, "if .A | f (len .B)"},
+		{Action{Pos: 36, End: 59, Left: "{{", Right: " -}}"}, "range $i, $x := .L"},
+	}
+	if len(actions) != len(expected) {
+		t.Fatalf("expected %d actions, got %d: %v", len(expected), len(actions), actions)
+	}
+	for i, a := range actions {
+		if a != expected[i] {
+			t.Errorf("action %d: expected %+v, got %+v", i, expected[i], a)
+		}
+	}
+}
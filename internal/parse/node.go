@@ -69,6 +69,7 @@ const (
 	NodeTemplate                   // A template invocation action.
 	NodeVariable                   // A $ variable.
 	NodeWith                       // A with action.
+	NodeError                      // Text that failed to parse.
 )
 
 // Nodes.
@@ -837,3 +838,29 @@ func (t *TemplateNode) tree() *Tree {
 func (t *TemplateNode) Copy() Node {
 	return t.tr.newTemplate(t.Pos, t.Line, t.Name, t.Pipe.CopyPipe())
 }
+
+// ErrorNode holds the text of an action that failed to parse, in a tree
+// parsed with ParseRecover.
+type ErrorNode struct {
+	NodeType
+	Pos
+	tr   *Tree
+	Text string // The text of the action, as it appears in the input.
+	Err  *Error // The error parsing it.
+}
+
+func (t *Tree) newErrorNode(pos Pos, text string, err *Error) *ErrorNode {
+	return &ErrorNode{tr: t, NodeType: NodeError, Pos: pos, Text: text, Err: err}
+}
+
+func (e *ErrorNode) String() string {
+	return e.Text
+}
+
+func (e *ErrorNode) tree() *Tree {
+	return e.tr
+}
+
+func (e *ErrorNode) Copy() Node {
+	return e.tr.newErrorNode(e.Pos, e.Text, e.Err)
+}
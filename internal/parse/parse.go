@@ -29,7 +29,8 @@ type Tree struct {
 	peekCount int
 	vars      []string // variables defined at the moment.
 	treeSet   map[string]*Tree
-	skipFuncs bool // if true, will notcheck that refernced functions exist in funcmap
+	skipFuncs bool      // if true, will notcheck that refernced functions exist in funcmap
+	errs      *[]*Error // if not nil, errors recovered from; see ParseRecover
 }
 
 // Copy returns a copy of the Tree. Any parsing state is discarded.
@@ -60,6 +61,24 @@ func Parse(name, text, leftDelim, rightDelim string, funcs ...map[string]interfa
 	return parse(name, text, leftDelim, rightDelim, false, funcs)
 }
 
+// ParseRecover is like ParseNoFuncs, but rather than stopping at the first
+// syntax error it records the error, skips to the end of the action it is in
+// and carries on, returning every error found. The text of the actions that
+// failed to parse is kept in ErrorNodes in the trees, and blocks left open at
+// the end of the text are closed.
+func ParseRecover(name, text, leftDelim, rightDelim string) (map[string]*Tree, []*Error) {
+	treeSet := make(map[string]*Tree)
+	t := New(name)
+	t.text = text
+	t.skipFuncs = true
+	var errs []*Error
+	t.errs = &errs
+	if _, err := t.Parse(text, leftDelim, rightDelim, treeSet); err != nil {
+		errs = append(errs, err.(*Error))
+	}
+	return treeSet, errs
+}
+
 func parse(name, text, leftDelim, rightDelim string, skipFuncs bool, funcs []map[string]interface{}) (map[string]*Tree, error) {
 	treeSet := make(map[string]*Tree)
 	t := New(name)
@@ -167,11 +186,82 @@ func (t *Tree) ErrorContext(n Node) (location, context string) {
 	return fmt.Sprintf("%s:%d:%d", tree.ParseName, lineNum, byteNum), context
 }
 
-// errorf formats the error and terminates processing.
+// Error is the error returned when parsing fails.
+type Error struct {
+	Name string // name of the top-level template being parsed
+	Line int    // line number of the offending token
+	Pos  Pos    // byte position of the offending token
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("template: %s:%d: %s", e.Name, e.Line, e.Msg)
+}
+
+// errorf formats the error and terminates processing, or when recovering from
+// errors, processing of the current item.
 func (t *Tree) errorf(format string, args ...interface{}) {
-	t.Root = nil
-	format = fmt.Sprintf("template: %s:%d: %s", t.ParseName, t.token[0].line, format)
-	panic(fmt.Errorf(format, args...))
+	if t.errs == nil {
+		t.Root = nil
+	}
+	panic(t.newError(format, args...))
+}
+
+// softErrorf is like errorf, but if the tree is recovering from errors it
+// records the error and returns.
+func (t *Tree) softErrorf(format string, args ...interface{}) {
+	if t.errs == nil {
+		t.errorf(format, args...)
+	}
+	*t.errs = append(*t.errs, t.newError(format, args...))
+}
+
+// newError returns an error at the current token.
+func (t *Tree) newError(format string, args ...interface{}) *Error {
+	return &Error{
+		Name: t.ParseName,
+		Line: t.token[0].line,
+		Pos:  t.token[0].pos,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// recoverItem returns the item parsed by parse. If the tree is recovering
+// from errors and parse fails, it records the error, skips the rest of the
+// action and returns an ErrorNode holding the text skipped.
+func (t *Tree) recoverItem(parse func() Node) (n Node) {
+	if t.errs == nil {
+		return parse()
+	}
+	start := t.peek().pos
+	defer func() {
+		e := recover()
+		if e == nil {
+			return
+		}
+		err, ok := e.(*Error)
+		if !ok {
+			panic(e)
+		}
+		*t.errs = append(*t.errs, err)
+		n = t.newErrorNode(start, t.text[start:t.skipAction()], err)
+	}()
+	return parse()
+}
+
+// skipAction skips the tokens up to the end of the current action, returning
+// the position just past them.
+func (t *Tree) skipAction() Pos {
+	for {
+		switch token := t.next(); token.typ {
+		case itemRightDelim:
+			return token.pos + Pos(len(token.val))
+		case itemLeftDelim, itemText, itemEOF:
+			// The lexer restarts at the next action after an error.
+			t.backup()
+			return token.pos
+		}
+	}
 }
 
 // error terminates processing.
@@ -241,7 +331,10 @@ func (t *Tree) stopParse() {
 func (t *Tree) Parse(text, leftDelim, rightDelim string, treeSet map[string]*Tree, funcs ...map[string]interface{}) (tree *Tree, err error) {
 	defer t.recover(&err)
 	t.ParseName = t.Name
-	t.startParse(funcs, lex(t.Name, text, leftDelim, rightDelim), treeSet)
+	l := newLexer(t.Name, text, leftDelim, rightDelim)
+	l.resync = t.errs != nil
+	go l.run()
+	t.startParse(funcs, l, treeSet)
 	t.text = text
 	t.parse()
 	t.add()
@@ -257,7 +350,7 @@ func (t *Tree) add() {
 		return
 	}
 	if !IsEmptyTree(t.Root) {
-		t.errorf("template: multiple definition of template %q", t.Name)
+		t.softErrorf("template: multiple definition of template %q", t.Name)
 	}
 }
 
@@ -280,6 +373,7 @@ func IsEmptyTree(n Node) bool {
 	case *TextNode:
 		return len(bytes.TrimSpace(n.Text)) == 0
 	case *WithNode:
+	case *ErrorNode:
 	default:
 		panic("unknown node: " + n.String())
 	}
@@ -292,28 +386,37 @@ func IsEmptyTree(n Node) bool {
 func (t *Tree) parse() {
 	t.Root = t.newList(t.peek().pos)
 	for t.peek().typ != itemEOF {
-		if t.peek().typ == itemLeftDelim {
-			delim := t.next()
-			if t.nextNonSpace().typ == itemDefine {
-				newT := New("definition") // name will be updated once we know it.
-				newT.text = t.text
-				newT.ParseName = t.ParseName
-				newT.skipFuncs = t.skipFuncs
-				newT.startParse(t.funcs, t.lex, t.treeSet)
-				newT.parseDefinition()
-				continue
-			}
-			t.backup2(delim)
-		}
-		switch n := t.textOrAction(); n.Type() {
-		case nodeEnd, nodeElse:
-			t.errorf("unexpected %s", n)
-		default:
+		if n := t.recoverItem(t.topItem); n != nil {
 			t.Root.append(n)
 		}
 	}
 }
 
+// topItem parses an item at the top level of a template, returning nil for
+// a {{define}}.
+func (t *Tree) topItem() Node {
+	if t.peek().typ == itemLeftDelim {
+		delim := t.next()
+		if t.nextNonSpace().typ == itemDefine {
+			newT := New("definition") // name will be updated once we know it.
+			newT.text = t.text
+			newT.ParseName = t.ParseName
+			newT.skipFuncs = t.skipFuncs
+			newT.errs = t.errs
+			newT.startParse(t.funcs, t.lex, t.treeSet)
+			newT.parseDefinition()
+			return nil
+		}
+		t.backup2(delim)
+	}
+	n := t.textOrAction()
+	switch n.Type() {
+	case nodeEnd, nodeElse:
+		t.errorf("unexpected %s", n)
+	}
+	return n
+}
+
 // parseDefinition parses a {{define}} ...  {{end}} template definition and
 // installs the definition in t.treeSet. The "define" keyword has already
 // been scanned.
@@ -341,15 +444,16 @@ func (t *Tree) parseDefinition() {
 func (t *Tree) itemList() (list *ListNode, next Node) {
 	list = t.newList(t.peekNonSpace().pos)
 	for t.peekNonSpace().typ != itemEOF {
-		n := t.textOrAction()
+		n := t.recoverItem(t.textOrAction)
 		switch n.Type() {
 		case nodeEnd, nodeElse:
 			return list, n
 		}
 		list.append(n)
 	}
-	t.errorf("unexpected EOF")
-	return
+	t.softErrorf("unexpected EOF")
+	// Recovering, so close the block.
+	return list, t.newEnd(t.peek().pos)
 }
 
 // textOrAction:
@@ -394,6 +498,10 @@ func (t *Tree) action() (n Node) {
 	return t.newAction(token.pos, token.line, t.pipeline("command"))
 }
 
+// parenPipeline is the context of a pipeline in parentheses, the only one
+// that a right paren may end.
+const parenPipeline = "parenthesized pipeline"
+
 // Pipeline:
 //	declarations? command ('|' command)*
 func (t *Tree) pipeline(context string) (pipe *PipeNode) {
@@ -432,6 +540,9 @@ func (t *Tree) pipeline(context string) (pipe *PipeNode) {
 	for {
 		switch token := t.nextNonSpace(); token.typ {
 		case itemRightDelim, itemRightParen:
+			if token.typ == itemRightParen && context != parenPipeline {
+				t.unexpected(token, "input")
+			}
 			// At this point, the pipeline is complete
 			t.checkPipeline(pipe, context)
 			if token.typ == itemRightParen {
@@ -556,6 +667,7 @@ func (t *Tree) blockControl() Node {
 	block := New(name) // name will be updated once we know it.
 	block.text = t.text
 	block.ParseName = t.ParseName
+	block.errs = t.errs
 	block.startParse(t.funcs, t.lex, t.treeSet)
 	var end Node
 	block.Root, end = block.itemList()
@@ -652,9 +764,9 @@ func (t *Tree) operand() Node {
 		// More complex error cases will have to be handled at execution time.
 		switch node.Type() {
 		case NodeField:
-			node = t.newField(chain.Position(), chain.String())
+			node = t.newField(node.Position(), chain.String())
 		case NodeVariable:
-			node = t.newVariable(chain.Position(), chain.String())
+			node = t.newVariable(node.Position(), chain.String())
 		case NodeBool, NodeString, NodeNumber, NodeNil, NodeDot:
 			t.errorf("unexpected . after term %q", node.String())
 		default:
@@ -699,7 +811,7 @@ func (t *Tree) term() Node {
 		}
 		return number
 	case itemLeftParen:
-		pipe := t.pipeline("parenthesized pipeline")
+		pipe := t.pipeline(parenPipeline)
 		if token := t.next(); token.typ != itemRightParen {
 			t.errorf("unclosed right paren: unexpected %s", token)
 		}
@@ -518,3 +518,65 @@ func BenchmarkParseLarge(b *testing.B) {
 		}
 	}
 }
+
+func TestErrorPos(t *testing.T) {
+	_, err := New("pos").Parse("line1\n{{.X}}{{foo}}", "", "", make(map[string]*Tree), builtins)
+	perr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error, got %T: %v", err, err)
+	}
+	if perr.Line != 2 || perr.Pos != 14 {
+		t.Errorf("expected error at line 2, pos 14; got line %d, pos %d", perr.Line, perr.Pos)
+	}
+	if s := perr.Error(); s != `template: pos:2: function "foo" not defined` {
+		t.Errorf("wrong error message %q", s)
+	}
+}
+
+func TestChainPos(t *testing.T) {
+	tree, err := New("pos").Parse("{{.X.Y}}{{$.Z}}", "", "", make(map[string]*Tree), builtins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := tree.Root.Nodes[0].(*ActionNode).Pipe.Cmds[0].Args[0]
+	if pos := field.Position(); pos != 2 {
+		t.Errorf("expected field at pos 2, got %d", pos)
+	}
+	variable := tree.Root.Nodes[1].(*ActionNode).Pipe.Cmds[0].Args[0]
+	if pos := variable.Position(); pos != 10 {
+		t.Errorf("expected variable at pos 10, got %d", pos)
+	}
+}
+
+func TestParseRecover(t *testing.T) {
+	text := "a{{.A ) }}b{{if .X}}{{.B}}{{end}}{{end}}c{{define \"x\"}}{{if}}{{end}}{{if .Y}}d{{.C"
+	trees, errs := ParseRecover("recover", text, "", "")
+	expected := []struct {
+		pos Pos
+		msg string
+	}{
+		{6, `unexpected ")" in input`},
+		{38, "unexpected {{end}}"},
+		{59, "missing value for if"},
+		{82, "unclosed action"},
+		{82, "unexpected EOF"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, e := range expected {
+		if errs[i].Pos != e.pos || errs[i].Msg != e.msg {
+			t.Errorf("error %d: expected %q at %d, got %q at %d", i, e.msg, e.pos, errs[i].Msg, errs[i].Pos)
+		}
+	}
+	if s, expected := trees["recover"].Root.String(), "a{{.A ) }}b{{if .X}}{{.B}}{{end}}{{end}}c{{if .Y}}d{{.C{{end}}"; s != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, s)
+	}
+	if s, expected := trees["x"].Root.String(), "{{if}}"; s != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, s)
+	}
+	n, ok := trees["recover"].Root.Nodes[1].(*ErrorNode)
+	if !ok || n.Pos != 1 || n.Err != errs[0] {
+		t.Errorf("expected error node at 1 for the first error, got %#v", trees["recover"].Root.Nodes[1])
+	}
+}
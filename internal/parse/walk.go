@@ -0,0 +1,64 @@
+package parse
+
+// Inspect traverses the tree rooted at node in lexical order. It starts by
+// calling f(node), which must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a call of
+// f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *ListNode:
+		for _, c := range n.Nodes {
+			Inspect(c, f)
+		}
+	case *ActionNode:
+		Inspect(n.Pipe, f)
+	case *PipeNode:
+		for _, d := range n.Decl {
+			Inspect(d, f)
+		}
+		for _, c := range n.Cmds {
+			Inspect(c, f)
+		}
+	case *CommandNode:
+		for _, a := range n.Args {
+			Inspect(a, f)
+		}
+	case *ChainNode:
+		Inspect(n.Node, f)
+	case *IfNode:
+		inspectBranch(&n.BranchNode, f)
+	case *RangeNode:
+		inspectBranch(&n.BranchNode, f)
+	case *WithNode:
+		inspectBranch(&n.BranchNode, f)
+	case *BranchNode:
+		inspectBranch(n, f)
+	case *TemplateNode:
+		Inspect(n.Pipe, f)
+	}
+	f(nil)
+}
+
+func inspectBranch(b *BranchNode, f func(Node) bool) {
+	Inspect(b.Pipe, f)
+	Inspect(b.List, f)
+	Inspect(b.ElseList, f)
+}
+
+// isNil reports whether node is nil or a nil pointer to a node.
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *ListNode:
+		return n == nil
+	case *PipeNode:
+		return n == nil
+	case *CommandNode:
+		return n == nil
+	}
+	return false
+}
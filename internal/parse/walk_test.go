@@ -0,0 +1,29 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	tree, err := New("inspect").Parse(`a{{if $x := .X}}{{printf "%d" $x.Y}}{{end}}{{template "t" (.Z)}}`, "", "", make(map[string]*Tree), builtins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []NodeType
+	Inspect(tree.Root, func(n Node) bool {
+		if n != nil {
+			got = append(got, n.Type())
+		}
+		return true
+	})
+	expected := []NodeType{
+		NodeList, NodeText,
+		NodeIf, NodePipe, NodeVariable, NodeCommand, NodeField,
+		NodeList, NodeAction, NodePipe, NodeCommand, NodeIdentifier, NodeString, NodeVariable,
+		NodeTemplate, NodePipe, NodeCommand, NodePipe, NodeCommand, NodeField,
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected:\n%v\n\ngot:\n%v", expected, got)
+	}
+}
@@ -0,0 +1,130 @@
+// Package sarif writes reports in the Static Analysis Results Interchange
+// Format (SARIF) 2.1.0, as consumed by code scanning dashboards.
+package sarif
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Levels of a result.
+const (
+	Error   = "error"
+	Warning = "warning"
+	Note    = "note"
+)
+
+// Log is a SARIF log holding the results of a single run of a tool.
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []*Run `json:"runs"`
+}
+
+// Run is a single invocation of a tool.
+type Run struct {
+	Tool       Tool      `json:"tool"`
+	ColumnKind string    `json:"columnKind"`
+	Results    []*Result `json:"results"`
+}
+
+// Tool describes the tool that produced a run.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver describes the tool and the rules it checks.
+type Driver struct {
+	Name           string  `json:"name"`
+	InformationURI string  `json:"informationUri,omitempty"`
+	Rules          []*Rule `json:"rules"`
+}
+
+// Rule describes a kind of result.
+type Rule struct {
+	ID               string  `json:"id"`
+	ShortDescription Message `json:"shortDescription"`
+}
+
+// Result is a single finding.
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations"`
+}
+
+// Message is the text of a description or result.
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Location is where a result was found.
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a region of a file.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation identifies a file.
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Region is a range of text in a file. Lines and columns are 1-based, and
+// columns count Unicode code points.
+type Region struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// New returns a log for a single run of the named tool.
+func New(tool, uri string) *Log {
+	return &Log{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []*Run{{
+			Tool:       Tool{Driver: Driver{Name: tool, InformationURI: uri, Rules: []*Rule{}}},
+			ColumnKind: "unicodeCodePoints",
+			Results:    []*Result{},
+		}},
+	}
+}
+
+// AddRule adds a rule to the log, if it isn't already present.
+func (l *Log) AddRule(id, description string) {
+	driver := &l.Runs[0].Tool.Driver
+	for _, r := range driver.Rules {
+		if r.ID == id {
+			return
+		}
+	}
+	driver.Rules = append(driver.Rules, &Rule{ID: id, ShortDescription: Message{Text: description}})
+}
+
+// Add adds a result for the given file to the log. Region may be nil if the
+// result applies to the whole file.
+func (l *Log) Add(ruleID, level, msg, path string, region *Region) {
+	l.Runs[0].Results = append(l.Runs[0].Results, &Result{
+		RuleID:  ruleID,
+		Level:   level,
+		Message: Message{Text: msg},
+		Locations: []Location{{PhysicalLocation: PhysicalLocation{
+			ArtifactLocation: ArtifactLocation{URI: path},
+			Region:           region,
+		}}},
+	})
+}
+
+// Write writes the log as indented JSON.
+func (l *Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
@@ -0,0 +1,49 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	l := New("gtfmt", "")
+	l.AddRule("parse-error", "template does not parse")
+	l.AddRule("parse-error", "template does not parse")
+	l.Add("parse-error", Error, "unexpected EOF", "a.tmpl", &Region{StartLine: 2, StartColumn: 3})
+	var buf bytes.Buffer
+	if err := l.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	var expected map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [{
+    "tool": {"driver": {"name": "gtfmt", "rules": [
+      {"id": "parse-error", "shortDescription": {"text": "template does not parse"}}
+    ]}},
+    "columnKind": "unicodeCodePoints",
+    "results": [{
+      "ruleId": "parse-error",
+      "level": "error",
+      "message": {"text": "unexpected EOF"},
+      "locations": [{"physicalLocation": {
+        "artifactLocation": {"uri": "a.tmpl"},
+        "region": {"startLine": 2, "startColumn": 3}
+      }}]
+    }]
+  }]
+}`), &expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected:\n%v\n\ngot:\n%s", expected, buf.String())
+	}
+}